@@ -0,0 +1,63 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/nboughton/stalotto/db"
+	"github.com/spf13/cobra"
+)
+
+// dbCmd represents the db command
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Maintain the application DB",
+	Long:  ``,
+}
+
+func init() {
+	RootCmd.AddCommand(dbCmd)
+}
+
+// sqlDB returns appDB as an *db.AppDB for commands that need a SQL database,
+// exiting if the DB is held in memory. Commands that store draws need one too as
+// anything written to memory would be lost on exit.
+func sqlDB(name string) *db.AppDB {
+	sqlDB, ok := appDB.(*db.AppDB)
+	if !ok {
+		fmt.Printf("%s is only supported by SQL databases, not JSON exports loaded into memory\n", name)
+		os.Exit(1)
+	}
+
+	return sqlDB
+}
+
+// currentUser returns the name recorded against manual changes by default
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
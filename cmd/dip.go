@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/nboughton/stalotto/db"
 	"github.com/nboughton/stalotto/lotto"
 	"github.com/spf13/cobra"
 )
@@ -36,16 +37,16 @@ var dipCmd = &cobra.Command{
 of balls was increased to 59) and removes the least drawn half before randomly drawing
 a set.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		set, begin, end := lotto.ResultSet{}, time.Date(2015, time.October, 10, 0, 0, 0, 0, time.Local), time.Now()
-		for res := range appDB.Results(begin, end, []string{}, []int{}, false) {
-			set = append(set, res)
+		balls, bonus, err := appDB.Frequencies(db.NewFilter(time.Date(2015, time.October, 10, 0, 0, 0, 0, time.Local), time.Now()))
+		if err != nil {
+			fmt.Println(err)
+			return
 		}
 
 		var (
-			balls, bonus = set.ByDrawFrequency()
-			nSet         = balls.Prune().Desc().Balls()
-			numbers      = nSet[:len(nSet)/2]
-			bonuses      = bonus.Prune().Desc().Balls()[:10]
+			nSet    = balls.Prune().Desc().Balls()
+			numbers = nSet[:len(nSet)/2]
+			bonuses = bonus.Prune().Desc().Balls()[:10]
 		)
 
 		fmt.Fprintf(tw, "Balls:\t%v\nBonus:\t%v\n", lotto.Draw(numbers, 6), lotto.Draw(bonuses, 1))
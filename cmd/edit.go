@@ -0,0 +1,106 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nboughton/stalotto/db"
+	"github.com/nboughton/stalotto/lotto"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flDate   = "date"
+	flAuthor = "author"
+	flDraw   = "draw"
+	flBalls  = "balls"
+)
+
+// editCmd represents the edit command
+var editCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Correct the stored values of a draw",
+	Long: `Edit changes the values of the draw on --date. Only the fields given are changed
+and each change is added to the draw's audit trail along with its old value, who
+made it and when. Corrected draws are never overwritten by later updates or imports.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		date := parseDateFlag(cmd)
+		author, _ := cmd.Flags().GetString(flAuthor)
+
+		var c db.Correction
+		if cmd.Flags().Changed(flDraw) {
+			n, _ := cmd.Flags().GetInt(flDraw)
+			c.Draw = &n
+		}
+		if cmd.Flags().Changed(flMachine) {
+			m, _ := cmd.Flags().GetString(flMachine)
+			c.Machine = &m
+		}
+		if cmd.Flags().Changed(flSet) {
+			n, _ := cmd.Flags().GetInt(flSet)
+			c.Set = &n
+		}
+		if cmd.Flags().Changed(flBalls) {
+			c.Balls, _ = cmd.Flags().GetIntSlice(flBalls)
+		}
+		if cmd.Flags().Changed(flBonus) {
+			n, _ := cmd.Flags().GetInt(flBonus)
+			c.Bonus = &n
+		}
+
+		res, err := sqlDB("edit").Correct(lotto.GAME, date, c, author)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println(res)
+	},
+}
+
+// parseDateFlag returns the value of the --date flag, exiting if it's missing or
+// can't be parsed
+func parseDateFlag(cmd *cobra.Command) time.Time {
+	s, _ := cmd.Flags().GetString(flDate)
+	if s == "" {
+		fmt.Println("--date is required")
+		os.Exit(1)
+	}
+
+	t, err := time.Parse(fmtDate, s)
+	chkDateErr(err)
+
+	return t
+}
+
+func init() {
+	dbCmd.AddCommand(editCmd)
+	editCmd.Flags().String(flDate, "", "Date of the draw to correct (YYYY-MM-DD)")
+	editCmd.Flags().String(flAuthor, currentUser(), "Name recorded against the change")
+	editCmd.Flags().Int(flDraw, 0, "Set the draw number")
+	editCmd.Flags().String(flMachine, "", "Set the machine")
+	editCmd.Flags().Int(flSet, 0, "Set the ball set")
+	editCmd.Flags().IntSlice(flBalls, []int{}, "Set the main balls, in the order drawn")
+	editCmd.Flags().Int(flBonus, 0, "Set the bonus ball")
+}
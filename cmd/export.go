@@ -22,30 +22,64 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/nboughton/go-utils/json/file"
+	"github.com/nboughton/stalotto/db"
+	"github.com/nboughton/stalotto/lotto"
 	"github.com/spf13/cobra"
 )
 
 const (
-	flExportFormat = "format" // I might add different export formats at some point
+	flExportFormat = "format"
 	flExportFile   = "output-file"
 )
 
 // exportCmd represents the export command
 var exportCmd = &cobra.Command{
 	Use:   "export",
-	Short: "Export a record set as a json file",
+	Short: "Export a record set as a json or csv file",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
+		set, err := resultsQuery(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		format, _ := cmd.Flags().GetString(flExportFormat)
 		outputFile, _ := cmd.Flags().GetString(flExportFile)
-		if err := file.Write(outputFile, resultsQuery(cmd)); err != nil {
+		if !cmd.Flags().Changed(flExportFile) {
+			outputFile = "stalotto-export." + format
+		}
+
+		switch format {
+		case db.FormatJSON:
+			err = file.Write(outputFile, set)
+		case db.FormatCSV:
+			err = writeCSV(outputFile, set)
+		default:
+			err = fmt.Errorf("unknown format %q", format)
+		}
+
+		if err != nil {
 			fmt.Println(err)
 		}
 	},
 }
 
+func writeCSV(path string, set lotto.ResultSet) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return set.WriteCSV(f)
+}
+
 func init() {
 	resultsCmd.AddCommand(exportCmd)
 	exportCmd.Flags().String(flExportFile, "stalotto-export.json", "Set output file path/name")
+	exportCmd.Flags().String(flExportFormat, db.FormatJSON, "Set output format (json or csv)")
 }
@@ -0,0 +1,51 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// gapsCmd represents the gaps command
+var gapsCmd = &cobra.Command{
+	Use:   "gaps",
+	Short: "Show how many draws each ball has gone without being drawn",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		gaps, err := appDB.Gaps(parseQueryFlags(cmd))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		fmt.Fprintln(tw, "Ball\tCurrent\tLongest")
+		for _, g := range gaps {
+			fmt.Fprintf(tw, "%d\t%d\t%d\n", g.Ball, g.Current, g.Longest)
+		}
+		tw.Flush()
+	},
+}
+
+func init() {
+	resultsCmd.AddCommand(gapsCmd)
+}
@@ -0,0 +1,59 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nboughton/stalotto/lotto"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the corrections and notes recorded against a draw",
+	Run: func(cmd *cobra.Command, args []string) {
+		date := parseDateFlag(cmd)
+
+		changes, notes, err := sqlDB("history").History(lotto.GAME, date)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Fprintln(tw, "When\tWho\tField\tOld\tNew")
+		for _, c := range changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.At.Format("2006-01-02 15:04"), c.Author, c.Field, c.Old, c.New)
+		}
+		tw.Flush()
+
+		for _, n := range notes {
+			fmt.Printf("\n%s %s:\n%s\n", n.At.Format("2006-01-02 15:04"), n.Author, n.Note)
+		}
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(historyCmd)
+	historyCmd.Flags().String(flDate, "", "Date of the draw (YYYY-MM-DD)")
+}
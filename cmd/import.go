@@ -0,0 +1,68 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nboughton/stalotto/db"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flImportFormat = "format"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import a record set from a json or csv export",
+	Long: `Import reads a file written by "stalotto results export" and stores each valid
+record, replacing any existing record for the same draw. --format operator reads the
+draw history CSV downloaded from the lottery operator instead.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString(flImportFormat)
+		store := sqlDB("import")
+
+		set, err := db.ReadFile(args[0], format)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		n, skipped, errs := db.Import(store, set)
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+
+		fmt.Printf("Imported %d records, skipped %d corrected by hand, rejected %d\n", n, skipped, len(errs))
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	importCmd.Flags().String(flImportFormat, db.FormatJSON, "Set input format (json, csv or operator)")
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/nboughton/stalotto/lotto"
 	"github.com/spf13/cobra"
 )
 
@@ -33,13 +34,21 @@ var leastCmd = &cobra.Command{
 	Short: "Get the least frequently drawn numbers from the constrained record set",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
-		var (
-			balls, bonus = resultsQuery(cmd).ByDrawFrequency()
-			sorted       = balls.Prune().Asc().Balls()[:6]
-		)
+		balls, bonus, err := appDB.Frequencies(parseQueryFlags(cmd))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 
+		drawn, bonuses := balls.Prune().Asc().Balls(), bonus.Prune().Asc().Balls()
+		if len(drawn) < lotto.BALLS || len(bonuses) == 0 {
+			fmt.Println("Not enough draws match the query")
+			return
+		}
+
+		sorted := drawn[:lotto.BALLS]
 		sort.Ints(sorted)
-		fmt.Println(sorted, bonus.Prune().Asc().Balls()[0])
+		fmt.Println(sorted, bonuses[0])
 	},
 }
 
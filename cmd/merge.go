@@ -0,0 +1,131 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nboughton/stalotto/db"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flPolicy = "policy"
+)
+
+// mergeCmd represents the merge command
+var mergeCmd = &cobra.Command{
+	Use:   "merge OTHER.db",
+	Short: "Reconcile the application DB with another stalotto DB",
+	Long: `Merge matches the draws in OTHER.db against the application DB by game, date and
+draw number. Draws missing from the application DB are added and values missing from
+either copy of a draw are filled in. Draws that hold different values are reported and
+resolved by --policy:
+
+  newest       keep whichever copy was fetched most recently
+  manual       keep a copy corrected by hand, otherwise the newest
+  interactive  ask which copy to keep
+
+Annotations are copied across and every change is added to the audit trail of the
+draw. Only draws and annotations are merged, there's no tickets table so tickets
+can't be carried across. OTHER.db may have been written by any version of stalotto,
+it's read through a temporary copy that's migrated to the current schema and isn't
+changed itself.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		policy, _ := cmd.Flags().GetString(flPolicy)
+		author, _ := cmd.Flags().GetString(flAuthor)
+
+		resolve := askResolver(bufio.NewReader(os.Stdin))
+		if policy != db.MergeInteractive {
+			var err error
+			if resolve, err = db.MergePolicy(policy); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		local := sqlDB("merge")
+		other, err := db.OpenCopy(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer other.Close()
+
+		report, err := local.Merge(other, resolve, author)
+		for _, c := range report.Conflicts {
+			kept := "local"
+			if c.Theirs {
+				kept = args[0]
+			}
+			fmt.Printf("Conflict: %s (local) and %s (%s), kept %s\n", c.Local, c.Other, args[0], kept)
+		}
+		for _, e := range report.Rejected {
+			fmt.Println("Rejected:", e)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Added %d draws, filled %d, %d unchanged, %d conflicts, %d rejected, copied %d annotations\n",
+			report.Added, report.Filled, report.Unchanged, len(report.Conflicts), len(report.Rejected), report.Annotations)
+	},
+}
+
+// askResolver returns a db.Resolver that shows each conflict and reads the copy to
+// keep from r
+func askResolver(r *bufio.Reader) db.Resolver {
+	return func(c db.Conflict) (bool, error) {
+		fmt.Printf("\n%s conflicts:\n", c.Local.Date.Format(fmtDate))
+		fmt.Fprintln(tw, "Field\tLocal\tOther")
+		for _, ch := range c.Changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", ch.Field, ch.Old, ch.New)
+		}
+		fmt.Fprintf(tw, "source\t%s\t%s\n", c.Local.Provenance.Source, c.Other.Provenance.Source)
+		tw.Flush()
+
+		for {
+			fmt.Print("Keep [l]ocal or [o]ther? ")
+			line, err := r.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "l", "local":
+				return false, nil
+			case "o", "other":
+				return true, nil
+			}
+
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+func init() {
+	dbCmd.AddCommand(mergeCmd)
+	mergeCmd.Flags().String(flPolicy, db.MergeManual, "Set how conflicts are resolved (newest, manual or interactive)")
+	mergeCmd.Flags().String(flAuthor, currentUser(), "Name recorded against changes made by the merge")
+}
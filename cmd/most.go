@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/nboughton/stalotto/lotto"
 	"github.com/spf13/cobra"
 )
 
@@ -33,13 +34,21 @@ var mostCmd = &cobra.Command{
 	Short: "Get the most frequently drawn numbers from the constrained record set",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
-		var (
-			balls, bonus = resultsQuery(cmd).ByDrawFrequency()
-			sorted       = balls.Prune().Desc().Balls()[:6]
-		)
+		balls, bonus, err := appDB.Frequencies(parseQueryFlags(cmd))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 
+		drawn, bonuses := balls.Prune().Desc().Balls(), bonus.Prune().Desc().Balls()
+		if len(drawn) < lotto.BALLS || len(bonuses) == 0 {
+			fmt.Println("Not enough draws match the query")
+			return
+		}
+
+		sorted := drawn[:lotto.BALLS]
 		sort.Ints(sorted)
-		fmt.Println(sorted, bonus.Prune().Desc().Balls()[0])
+		fmt.Println(sorted, bonuses[0])
 	},
 }
 
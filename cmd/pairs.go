@@ -0,0 +1,57 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flPairsTop = "top"
+)
+
+// pairsCmd represents the pairs command
+var pairsCmd = &cobra.Command{
+	Use:   "pairs",
+	Short: "Show the pairs of balls most frequently drawn together",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		top, _ := cmd.Flags().GetInt(flPairsTop)
+		pairs, err := appDB.CoOccurrence(parseQueryFlags(cmd), top)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		fmt.Fprintln(tw, "Balls\tDraws")
+		for _, p := range pairs {
+			fmt.Fprintf(tw, "%d %d\t%d\n", p.A, p.B, p.Freq)
+		}
+		tw.Flush()
+	},
+}
+
+func init() {
+	resultsCmd.AddCommand(pairsCmd)
+	pairsCmd.Flags().Int(flPairsTop, 10, "Number of pairs to show")
+}
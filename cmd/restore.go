@@ -0,0 +1,82 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flList = "list"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [FILE]",
+	Short: "Replace the application DB with a backup",
+	Long: `Restore replaces the contents of the DB with the backup in FILE, or with the
+latest snapshot in --snapshot-dir if FILE isn't given. A snapshot of the DB is taken
+first so that a restore can itself be undone.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sqlDB := sqlDB("restore")
+
+		snaps, err := sqlDB.Snapshots()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if list, _ := cmd.Flags().GetBool(flList); list {
+			for _, s := range snaps {
+				fmt.Println(s)
+			}
+			return
+		}
+
+		var path string
+		switch {
+		case len(args) == 1:
+			path = args[0]
+		case len(snaps) > 0:
+			path = snaps[len(snaps)-1]
+		default:
+			fmt.Println("No snapshots found")
+			os.Exit(1)
+		}
+
+		prev, err := sqlDB.Restore(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Restored %s, the previous DB was saved to %s\n", path, prev)
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().Bool(flList, false, "List the snapshots that can be restored")
+}
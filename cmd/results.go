@@ -21,10 +21,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/nboughton/stalotto/db"
 	"github.com/nboughton/stalotto/lotto"
 	"github.com/spf13/cobra"
 )
@@ -34,6 +38,18 @@ const (
 	flEnd     = "end"
 	flMachine = "machine"
 	flSet     = "set"
+	flDay     = "day"
+	flSumMin  = "sum-min"
+	flSumMax  = "sum-max"
+	flLimit   = "limit"
+	flOffset  = "offset"
+	flSort    = "sort"
+	flAsc     = "asc"
+	flHas     = "has"
+	flHasAny  = "has-any"
+	flBonus   = "bonus"
+	flSource  = "show-source"
+	flEvent   = "event"
 )
 
 var fmtDate = "2006-01-02"
@@ -42,26 +58,71 @@ var fmtDate = "2006-01-02"
 var resultsCmd = &cobra.Command{
 	Use:   "results",
 	Short: "Retrieve/Print/Export a result set",
-	Long:  `--begin and --end dates must be formatted as YYYY-MM-DD`,
+	Long: `--begin and --end dates must be formatted as YYYY-MM-DD. --day takes weekday
+names (e.g. wed, sat) and --sort takes one of date, sum, bonus, set, machine or
+draw. --has 7,23 matches draws containing both 7 and 23 as main balls, --has-any
+7,23 matches draws containing either of them. --draw takes official draw numbers
+and --event takes special events (must-be-won, raffle).`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Fprintln(tw, "DATE\tSET\tMACHINE\tB1\tB2\tB3\tB4\tB5\tB6\tBONUS")
-		for _, r := range resultsQuery(cmd) {
-			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", r.Date.Format("06/01/02"), r.Set, r.Machine, r.Balls[0], r.Balls[1], r.Balls[2], r.Balls[3], r.Balls[4], r.Balls[5], r.Bonus)
+		set, err := resultsQuery(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		showSource, _ := cmd.Flags().GetBool(flSource)
+
+		fmt.Fprint(tw, "DATE\tDRAW\tSET\tMACHINE\tB1\tB2\tB3\tB4\tB5\tB6\tBONUS\tEVENTS")
+		if showSource {
+			fmt.Fprint(tw, "\tFETCHED\tPARSER\tHASH\tSOURCE")
+		}
+		fmt.Fprintln(tw)
+
+		for _, r := range set {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s", r.Date.Format("06/01/02"), formatDraw(r.Draw), r.Set, r.Machine, r.Balls[0], r.Balls[1], r.Balls[2], r.Balls[3], r.Balls[4], r.Balls[5], r.Bonus, strings.Join(r.Events, ","))
+			if showSource {
+				fmt.Fprintf(tw, "\t%s\t%s\t%s\t%s", formatFetched(r.Provenance.FetchedAt), r.Provenance.ParserVersion, shortHash(r.Provenance.Hash), r.Provenance.Source)
+			}
+			fmt.Fprintln(tw)
 		}
 		tw.Flush()
 	},
 }
 
-func resultsQuery(cmd *cobra.Command) lotto.ResultSet {
+func formatDraw(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
+
+func formatFetched(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+func resultsQuery(cmd *cobra.Command) (lotto.ResultSet, error) {
+	results, errc := appDB.Results(context.Background(), parseQueryFlags(cmd))
+
 	var set lotto.ResultSet
-	for res := range appDB.Results(parseQueryFlags(cmd)) {
+	for res := range results {
 		set = append(set, res)
 	}
 
-	return set
+	return set, <-errc
 }
 
-func parseQueryFlags(cmd *cobra.Command) (time.Time, time.Time, []string, []int, bool) {
+// parseQueryFlags builds a db.Filter from the flags shared by the results commands
+func parseQueryFlags(cmd *cobra.Command) db.Filter {
 	bStr, _ := cmd.Flags().GetString(flBegin)
 	begin, err := time.Parse(fmtDate, bStr)
 	chkDateErr(err)
@@ -72,8 +133,60 @@ func parseQueryFlags(cmd *cobra.Command) (time.Time, time.Time, []string, []int,
 
 	machines, _ := cmd.Flags().GetStringArray(flMachine)
 	sets, _ := cmd.Flags().GetIntSlice(flSet)
+	dayNames, _ := cmd.Flags().GetStringSlice(flDay)
+	sumMin, _ := cmd.Flags().GetInt(flSumMin)
+	sumMax, _ := cmd.Flags().GetInt(flSumMax)
+	limit, _ := cmd.Flags().GetInt(flLimit)
+	offset, _ := cmd.Flags().GetInt(flOffset)
+	sortBy, _ := cmd.Flags().GetString(flSort)
+	asc, _ := cmd.Flags().GetBool(flAsc)
+	has, _ := cmd.Flags().GetIntSlice(flHas)
+	hasAny, _ := cmd.Flags().GetIntSlice(flHasAny)
+	bonus, _ := cmd.Flags().GetInt(flBonus)
+	draws, _ := cmd.Flags().GetIntSlice(flDraw)
+	events, _ := cmd.Flags().GetStringSlice(flEvent)
+
+	var days []time.Weekday
+	for _, name := range dayNames {
+		d, err := parseWeekday(name)
+		chkFilterErr(err)
+		days = append(days, d)
+	}
+
+	f := db.NewFilter(begin, end).
+		WithMachines(machines...).
+		WithSets(sets...).
+		WithDays(days...).
+		WithDraws(draws...).
+		WithEvents(events...).
+		WithAll(has...).
+		WithAny(hasAny...).
+		WithBonus(bonus).
+		WithSum(sumMin, sumMax).
+		WithPage(limit, offset).
+		WithSort(sortBy, !asc)
+	chkFilterErr(f.Validate())
+
+	return f
+}
+
+// parseWeekday accepts full or abbreviated weekday names in any case
+func parseWeekday(name string) (time.Weekday, error) {
+	name = strings.ToLower(name)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if len(name) >= 3 && strings.HasPrefix(strings.ToLower(d.String()), name) {
+			return d, nil
+		}
+	}
+
+	return time.Sunday, fmt.Errorf("unknown day %q", name)
+}
 
-	return begin, end, machines, sets, true
+func chkFilterErr(e error) {
+	if e != nil {
+		fmt.Printf("Invalid query (%s)\n", e)
+		os.Exit(1)
+	}
 }
 
 func chkDateErr(e error) {
@@ -85,8 +198,21 @@ func chkDateErr(e error) {
 
 func init() {
 	RootCmd.AddCommand(resultsCmd)
+	resultsCmd.Flags().Bool(flSource, false, "Show where each draw came from and when it was fetched")
 	resultsCmd.PersistentFlags().String(flBegin, "2015-10-10", "Set beginning date for query")
 	resultsCmd.PersistentFlags().String(flEnd, time.Now().Format(fmtDate), "Set end date for query")
 	resultsCmd.PersistentFlags().StringArrayP(flMachine, "m", []string{}, "Constrain results by machine")
 	resultsCmd.PersistentFlags().IntSliceP(flSet, "s", []int{}, "Constrain results by Set")
+	resultsCmd.PersistentFlags().StringSlice(flDay, []string{}, "Constrain results by day of the week")
+	resultsCmd.PersistentFlags().IntSlice(flHas, []int{}, "Constrain results to draws containing all of these balls")
+	resultsCmd.PersistentFlags().IntSlice(flHasAny, []int{}, "Constrain results to draws containing any of these balls")
+	resultsCmd.PersistentFlags().Int(flBonus, 0, "Constrain results to draws with this bonus ball")
+	resultsCmd.PersistentFlags().IntSlice(flDraw, []int{}, "Constrain results to draws with these official draw numbers")
+	resultsCmd.PersistentFlags().StringSlice(flEvent, []string{}, "Constrain results to draws that were part of any of these special events")
+	resultsCmd.PersistentFlags().Int(flSumMin, 0, "Constrain results by minimum sum of the main balls")
+	resultsCmd.PersistentFlags().Int(flSumMax, 0, "Constrain results by maximum sum of the main balls")
+	resultsCmd.PersistentFlags().Int(flLimit, 0, "Limit the number of draws in the query")
+	resultsCmd.PersistentFlags().Int(flOffset, 0, "Skip this many draws before applying the limit")
+	resultsCmd.PersistentFlags().String(flSort, db.SortDate, "Sort draws by date, sum, bonus, set, machine or draw")
+	resultsCmd.PersistentFlags().Bool(flAsc, false, "Sort draws in ascending order")
 }
@@ -31,13 +31,17 @@ import (
 
 // Flag const names
 const (
-	flDBPath = "db"
+	flDBPath       = "db"
+	flReadOnly     = "read-only"
+	flSnapshotDir  = "snapshot-dir"
+	flKeep         = "keep-snapshots"
+	flAutoSnapshot = "auto-snapshot"
 )
 
 var (
 	// tabwriter for any text that needs formatting
 	tw    = tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
-	appDB *db.AppDB
+	appDB db.Store
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -47,7 +51,12 @@ var RootCmd = &cobra.Command{
 	Long:  ``,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		dbPath, _ := cmd.Flags().GetString(flDBPath)
-		appDB = db.Connect(dbPath)
+		var opts db.Options
+		opts.ReadOnly, _ = cmd.Flags().GetBool(flReadOnly)
+		opts.SnapshotDir, _ = cmd.Flags().GetString(flSnapshotDir)
+		opts.Keep, _ = cmd.Flags().GetInt(flKeep)
+		opts.AutoSnapshot, _ = cmd.Flags().GetBool(flAutoSnapshot)
+		appDB = db.Open(dbPath, opts)
 	},
 }
 
@@ -61,5 +70,9 @@ func Execute() {
 }
 
 func init() {
-	RootCmd.PersistentFlags().String(flDBPath, fmt.Sprintf("%s/.cache/stalotto/data.db", os.Getenv("HOME")), "Set path to application db")
+	RootCmd.PersistentFlags().String(flDBPath, fmt.Sprintf("%s/.cache/stalotto/data.db", os.Getenv("HOME")), "Set path to application db or a postgres:// DSN, paths ending in .json are loaded read-only from an export into memory")
+	RootCmd.PersistentFlags().Bool(flReadOnly, false, "Open the application db read-only")
+	RootCmd.PersistentFlags().String(flSnapshotDir, fmt.Sprintf("%s/.cache/stalotto/snapshots", os.Getenv("HOME")), "Set directory that db snapshots are kept in")
+	RootCmd.PersistentFlags().Int(flKeep, 10, "Set number of db snapshots to keep, 0 keeps every snapshot")
+	RootCmd.PersistentFlags().Bool(flAutoSnapshot, false, "Snapshot the db before migrating it or running a full update")
 }
@@ -0,0 +1,115 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flGolden = "golden"
+	flRecord = "record"
+)
+
+// scraperCmd represents the scraper command
+var scraperCmd = &cobra.Command{
+	Use:   "scraper",
+	Short: "Check the lottery.co.uk scraper",
+	Long:  ``,
+}
+
+// selftestCmd represents the scraper selftest command
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check that the scraper can still parse the site",
+	Long: `selftest parses the golden pages recorded in --golden and compares them with the
+results recorded alongside them, then parses the latest live archive and result
+pages. Pages missing the elements the scraper reads, with the wrong number of balls
+or with dates that can't be parsed are reported and the command exits with an
+error. Updates stop at such pages rather than store what was parsed from them.
+
+--record saves the latest live pages as golden pages once they've passed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString(flGolden)
+		record, _ := cmd.Flags().GetBool(flRecord)
+
+		s, err := newScraper(cmd)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		checks, err := s.SelfTest(context.Background(), dir)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		failed := 0
+		for _, c := range checks {
+			kind := "golden"
+			if c.Live {
+				kind = "live"
+			}
+
+			if c.OK() {
+				fmt.Printf("ok   %-6s %s\n", kind, c.Page)
+				continue
+			}
+
+			failed++
+			fmt.Printf("FAIL %-6s %s\n", kind, c.Page)
+			for _, p := range c.Problems {
+				fmt.Printf("       %s\n", p)
+			}
+		}
+
+		if failed > 0 {
+			fmt.Printf("%d of %d pages failed\n", failed, len(checks))
+			os.Exit(1)
+		}
+
+		if !record {
+			return
+		}
+
+		pages, err := s.RecordGolden(context.Background(), dir)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, p := range pages {
+			fmt.Printf("Recorded %s as %s\n", p.Path, p.File)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(scraperCmd)
+	scraperCmd.AddCommand(selftestCmd)
+	selftestCmd.Flags().String(flGolden, fmt.Sprintf("%s/.cache/stalotto/golden", os.Getenv("HOME")), "Set directory that golden pages are kept in")
+	selftestCmd.Flags().Bool(flRecord, false, "Record the latest live pages as golden pages if every check passes")
+	addScraperFlags(selftestCmd)
+}
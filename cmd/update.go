@@ -22,22 +22,260 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/nboughton/stalotto/db"
+	"github.com/nboughton/stalotto/lotto"
 	"github.com/spf13/cobra"
 )
 
+const (
+	flFull         = "full"
+	flSince        = "since"
+	flUpdateSource = "source"
+	flBaseURL      = "base-url"
+	flUserAgent    = "user-agent"
+	flProxy        = "proxy"
+	flTimeout      = "timeout"
+	flRate         = "rate"
+	flRetries      = "retries"
+	flWorkers      = "workers"
+	flCacheDir     = "cache-dir"
+	flOffline      = "offline"
+	flReplay       = "replay"
+	flFrom         = "from"
+	flTo           = "to"
+	flFromYear     = "from-year"
+	flToYear       = "to-year"
+)
+
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update or create the DB",
-	Long:  ``,
+	Long: `By default update fetches new draws until it finds one that is already stored.
+--full checks the draw schedule from the first draw onwards and fetches any draws
+that are missing from the DB, --since does the same from a given YYYY-MM-DD date.
+
+--from and --to (YYYY-MM-DD), or --from-year and --to-year, bound the draws that are
+fetched. A bounded update fetches every draw in the window and replaces what is
+stored, other than manual corrections, so it can backfill or re-scrape a period.
+With --full or --since only the missing draws in the window are fetched:
+
+  stalotto update --from-year 2003 --to-year 2003
+  stalotto update --full --from 2015-10-10
+
+Results are scraped from lottery.co.uk unless --source names other sources. Files
+are named by format and path, such as csv:results.csv or operator:history.csv. When
+more than one source is given each is tried in turn if those before it fail.
+
+The scraper can be pointed at a mirror of lottery.co.uk with --base-url. Requests
+are sent through --proxy, or any proxy set in the environment, and give up after
+--timeout. The scraper makes at most --rate requests a second, or fewer if the
+site's robots.txt asks for a longer crawl delay, and never fetches pages that
+robots.txt disallows. Failed requests are retried up to --retries times with
+increasing delays and any pages that still fail are listed once the update ends.
+Up to --workers result pages from each year of the archive are fetched at once.
+
+Every page the scraper fetches is saved to --cache-dir. With --offline the scraper
+reads pages from the cache instead of the site, and --replay does the same from
+another cache directory, so a DB can be rebuilt without network access:
+
+  stalotto --db rebuilt.db update --full --replay pages/`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := appDB.Update(); err != nil {
+		full, _ := cmd.Flags().GetBool(flFull)
+		sinceStr, _ := cmd.Flags().GetString(flSince)
+		store := sqlDB("update")
+
+		src, err := parseSources(cmd)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		begin, end, bounded, err := parseBounds(cmd)
+		if err != nil {
 			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if !full && sinceStr == "" {
+			if !bounded {
+				if err := db.Update(store, src, lotto.Lotto); err != nil {
+					fmt.Println(err)
+				}
+				return
+			}
+
+			n, skipped, err := db.Refresh(store, src, lotto.Lotto, begin, end)
+			if err != nil {
+				fmt.Println(err)
+			}
+			fmt.Printf("Stored %d draws between %s and %s, skipped %d corrected by hand\n", n, begin.Format(fmtDate), end.Format(fmtDate), skipped)
+			return
+		}
+
+		if !full {
+			since, err := time.Parse(fmtDate, sinceStr)
+			chkDateErr(err)
+			if since.After(begin) {
+				begin = since
+			}
+		}
+
+		// Pages the scraper gave up on are listed but don't stop the summary
+		n, err := db.Resync(store, src, lotto.Lotto, begin, end)
+		if err != nil {
+			fmt.Println(err)
+			if _, ok := err.(*db.ScrapeError); !ok {
+				return
+			}
+		}
+
+		missing, err := store.Missing(lotto.Lotto, begin, end)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		fmt.Printf("Inserted %d draws, %d still missing\n", n, len(missing))
+		for _, d := range missing {
+			fmt.Println(d.Format(fmtDate))
 		}
 	},
 }
 
+// parseBounds returns the window set by --from, --to, --from-year and --to-year,
+// which defaults to every draw up to now. bounded is false if no bound was set.
+func parseBounds(cmd *cobra.Command) (begin, end time.Time, bounded bool, err error) {
+	begin, end = lotto.Lotto.FirstDraw(), time.Now()
+
+	if cmd.Flags().Changed(flFrom) && cmd.Flags().Changed(flFromYear) {
+		return begin, end, true, fmt.Errorf("--%s and --%s can't be used together", flFrom, flFromYear)
+	}
+	if cmd.Flags().Changed(flTo) && cmd.Flags().Changed(flToYear) {
+		return begin, end, true, fmt.Errorf("--%s and --%s can't be used together", flTo, flToYear)
+	}
+
+	if cmd.Flags().Changed(flFromYear) {
+		year, _ := cmd.Flags().GetInt(flFromYear)
+		begin, bounded = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), true
+	}
+	if cmd.Flags().Changed(flToYear) {
+		year, _ := cmd.Flags().GetInt(flToYear)
+		end, bounded = time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC), true
+	}
+
+	if cmd.Flags().Changed(flFrom) {
+		str, _ := cmd.Flags().GetString(flFrom)
+		begin, err = time.Parse(fmtDate, str)
+		chkDateErr(err)
+		bounded = true
+	}
+	if cmd.Flags().Changed(flTo) {
+		str, _ := cmd.Flags().GetString(flTo)
+		end, err = time.Parse(fmtDate, str)
+		chkDateErr(err)
+		bounded = true
+	}
+
+	if begin.Before(lotto.Lotto.FirstDraw()) {
+		begin = lotto.Lotto.FirstDraw()
+	}
+	if end.After(time.Now()) {
+		end = time.Now()
+	}
+	if begin.After(end) {
+		return begin, end, bounded, fmt.Errorf("the window from %s to %s is empty", begin.Format(fmtDate), end.Format(fmtDate))
+	}
+
+	return begin, end, bounded, nil
+}
+
+// parseSources returns the source named by --source, or a db.Fallback if more than
+// one source is named
+func parseSources(cmd *cobra.Command) (db.Source, error) {
+	names, _ := cmd.Flags().GetStringSlice(flUpdateSource)
+
+	scraper, err := newScraper(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var srcs db.Fallback
+	for _, name := range names {
+		if name == db.ScraperName {
+			srcs = append(srcs, scraper)
+			continue
+		}
+
+		src, err := db.SourceByName(name)
+		if err != nil {
+			return nil, err
+		}
+		srcs = append(srcs, src)
+	}
+
+	switch len(srcs) {
+	case 0:
+		return scraper, nil
+	case 1:
+		return srcs[0], nil
+	}
+	return srcs, nil
+}
+
+// newScraper returns a scraper configured by the scraper flags
+func newScraper(cmd *cobra.Command) (*db.Scraper, error) {
+	baseURL, _ := cmd.Flags().GetString(flBaseURL)
+	userAgent, _ := cmd.Flags().GetString(flUserAgent)
+	proxy, _ := cmd.Flags().GetString(flProxy)
+	timeout, _ := cmd.Flags().GetDuration(flTimeout)
+	rate, _ := cmd.Flags().GetFloat64(flRate)
+	retries, _ := cmd.Flags().GetInt(flRetries)
+	workers, _ := cmd.Flags().GetInt(flWorkers)
+	cacheDir, _ := cmd.Flags().GetString(flCacheDir)
+	offline, _ := cmd.Flags().GetBool(flOffline)
+	replay, _ := cmd.Flags().GetString(flReplay)
+
+	client, err := db.NewHTTPClient(timeout, proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	s := db.NewScraper(client, baseURL, userAgent)
+	s.Rate, s.Retries, s.Workers = rate, retries, workers
+	s.CacheDir, s.Offline = cacheDir, offline
+	if replay != "" {
+		s.CacheDir, s.Offline = replay, true
+	}
+
+	return s, nil
+}
+
 func init() {
 	RootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().Bool(flFull, false, "Fetch every missing draw since the first draw")
+	updateCmd.Flags().String(flSince, "", "Fetch every missing draw since date (YYYY-MM-DD)")
+	updateCmd.Flags().String(flFrom, "", "Only fetch draws made on or after date (YYYY-MM-DD)")
+	updateCmd.Flags().String(flTo, "", "Only fetch draws made on or before date (YYYY-MM-DD)")
+	updateCmd.Flags().Int(flFromYear, 0, "Only fetch draws made in or after year")
+	updateCmd.Flags().Int(flToYear, 0, "Only fetch draws made in or before year")
+	updateCmd.Flags().StringSlice(flUpdateSource, []string{db.ScraperName}, "Set the sources to fetch results from, in order of preference")
+	addScraperFlags(updateCmd)
+}
+
+// addScraperFlags adds the flags read by newScraper to cmd
+func addScraperFlags(cmd *cobra.Command) {
+	cmd.Flags().String(flBaseURL, db.DefaultBaseURL, "Set the site the scraper fetches pages from")
+	cmd.Flags().String(flUserAgent, db.DefaultUserAgent, "Set the User-Agent sent by the scraper")
+	cmd.Flags().String(flProxy, "", "Send scraper requests through a proxy (default from the environment)")
+	cmd.Flags().Duration(flTimeout, db.DefaultTimeout, "Give up on a scraper request after timeout")
+	cmd.Flags().Float64(flRate, db.DefaultRate, "Set the most requests a second the scraper makes, 0 for no limit")
+	cmd.Flags().Int(flRetries, db.DefaultRetries, "Set how many times the scraper retries a failed request")
+	cmd.Flags().Int(flWorkers, db.DefaultWorkers, "Set how many result pages the scraper fetches at once")
+	cmd.Flags().String(flCacheDir, fmt.Sprintf("%s/.cache/stalotto/pages", os.Getenv("HOME")), "Set directory that scraped pages are saved in, empty to save none")
+	cmd.Flags().Bool(flOffline, false, "Scrape pages from the cache directory instead of the site")
+	cmd.Flags().String(flReplay, "", "Scrape pages from a cache directory instead of the site")
 }
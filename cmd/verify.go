@@ -0,0 +1,60 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nboughton/stalotto/db"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check every stored draw for invalid or suspect data",
+	Long: `Verify reports draws that break the rules of their game (balls out of range,
+repeated balls, a bonus ball that is also a main ball), impossible dates, draws on
+days the game isn't scheduled, draws missing their machine or ball set and more than
+one draw of a game stored for the same date.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		issues, err := db.Verify(context.Background(), appDB)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, i := range issues {
+			fmt.Println(i.Problem)
+		}
+
+		fmt.Printf("%d issues found\n", len(issues))
+		if len(issues) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(verifyCmd)
+}
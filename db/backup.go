@@ -0,0 +1,297 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// fmtSnapshot is the timestamp added to snapshot file names. It sorts in the order
+// snapshots were taken.
+const fmtSnapshot = "20060102-150405.000"
+
+// errNoBackup is returned when backing up or restoring a database that doesn't use
+// SQLite's backup API
+var errNoBackup = errors.New("backups are only supported by SQLite databases")
+
+// Backup copies the database to path using SQLite's online backup API so that it
+// can be copied while it's in use. The copy is written alongside path and renamed
+// into place once complete.
+func (db *AppDB) Backup(path string) error {
+	if db.dialect != sqlite {
+		return errNoBackup
+	}
+
+	tmp := path + ".tmp"
+	dst, err := sql.Open(sqlite.driver, tmp)
+	if err != nil {
+		return err
+	}
+
+	// The copy takes the WAL journal mode of the database, switch it back so that
+	// the backup is a single self-contained file
+	if err := backup(dst, db.read); err == nil {
+		_, err = dst.Exec("PRAGMA journal_mode=DELETE")
+	}
+	if err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Snapshot backs the database up to a timestamped file in the snapshot directory
+// and removes the oldest snapshots beyond the number to be kept. It returns the
+// path of the new snapshot.
+func (db *AppDB) Snapshot() (string, error) {
+	path, err := db.snapshot()
+	if err != nil {
+		return "", err
+	}
+
+	return path, db.prune()
+}
+
+func (db *AppDB) snapshot() (string, error) {
+	if db.opts.SnapshotDir == "" {
+		return "", fmt.Errorf("no snapshot directory set")
+	}
+
+	if err := os.MkdirAll(db.opts.SnapshotDir, 0770); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(db.opts.SnapshotDir, fmt.Sprintf("%s-%s.db", db.snapshotName(), time.Now().UTC().Format(fmtSnapshot)))
+	return path, db.Backup(path)
+}
+
+// prune removes the oldest snapshots beyond the number to be kept
+func (db *AppDB) prune() error {
+	snaps, err := db.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	for db.opts.Keep > 0 && len(snaps) > db.opts.Keep {
+		if err := os.Remove(snaps[0]); err != nil {
+			return err
+		}
+		os.Remove(snaps[0] + "-wal")
+		os.Remove(snaps[0] + "-shm")
+		snaps = snaps[1:]
+	}
+
+	return nil
+}
+
+// Snapshots returns the paths of the snapshots of the database held in the snapshot
+// directory, oldest first
+func (db *AppDB) Snapshots() ([]string, error) {
+	if db.opts.SnapshotDir == "" {
+		return nil, nil
+	}
+
+	files, err := ioutil.ReadDir(db.opts.SnapshotDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only a timestamp may follow the name, or snapshots of a database called
+	// name-foo would be taken for snapshots of name
+	var (
+		prefix = db.snapshotName() + "-"
+		paths  []string
+	)
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".db")
+		if _, err := time.Parse(fmtSnapshot, stamp); len(stamp) != len(fmtSnapshot) || err != nil {
+			continue
+		}
+		paths = append(paths, filepath.Join(db.opts.SnapshotDir, name))
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// snapshotName returns the name snapshots of the database are prefixed with
+func (db *AppDB) snapshotName() string {
+	base := filepath.Base(db.path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// autoSnapshot takes a snapshot before reason if automatic snapshots are enabled
+func (db *AppDB) autoSnapshot(reason string) error {
+	if !db.opts.AutoSnapshot || db.dialect != sqlite {
+		return nil
+	}
+
+	path, err := db.Snapshot()
+	if err != nil {
+		return fmt.Errorf("snapshot before %s: %s", reason, err)
+	}
+	log.Printf("Snapshot taken before %s: %s\n", reason, path)
+
+	return nil
+}
+
+// Restore replaces the contents of the database with the backup at path. A snapshot
+// of the database is taken first and its path returned so that the restore can be
+// undone. Backups taken by older versions are migrated once restored.
+func (db *AppDB) Restore(path string) (string, error) {
+	if db.dialect != sqlite {
+		return "", errNoBackup
+	}
+
+	if db.opts.ReadOnly {
+		return "", ErrReadOnly
+	}
+
+	// The driver sets the journal mode of every connection it opens, which fails
+	// against a read-only database unless it's already in that mode
+	params, err := journalParams(path)
+	if err != nil {
+		return "", err
+	}
+
+	src, err := sql.Open(sqlite.driver, "file:"+path+"?mode=ro&"+params)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	prev, err := db.snapshot()
+	if err != nil {
+		return "", err
+	}
+
+	if err := backup(db.DB, src); err != nil {
+		return prev, err
+	}
+
+	// The backup itself is left as it was so there's no need to snapshot it again
+	if err := db.migrate(false); err != nil {
+		return prev, err
+	}
+
+	return prev, db.prune()
+}
+
+// OpenCopy opens a private copy of the SQLite database at path that has been
+// migrated to the current schema. Databases written by any version in any journal
+// mode can be read through it without being changed. The copy is removed when it's
+// closed.
+func OpenCopy(path string) (*AppDB, error) {
+	params, err := journalParams(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := sql.Open(sqlite.driver, "file:"+path+"?mode=ro&"+params)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	f, err := ioutil.TempFile("", "stalotto-*.db")
+	if err != nil {
+		return nil, err
+	}
+	tmp := f.Name()
+	f.Close()
+
+	dst, err := sql.Open(sqlite.driver, tmp)
+	if err == nil {
+		err = backup(dst, src)
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	db := Connect(tmp, Options{})
+	db.temp = true
+
+	return db, nil
+}
+
+// journalParams returns the connection parameters that match the journal mode of
+// the SQLite database at path
+func journalParams(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Bytes 18 and 19 of the header are 2 for databases in WAL mode
+	hdr := make([]byte, 20)
+	if _, err := io.ReadFull(f, hdr); err != nil {
+		return "", fmt.Errorf("%s is not a SQLite database", path)
+	}
+
+	if hdr[18] == 2 {
+		return sqlParams, nil
+	}
+	return "_journal_mode=DELETE&_busy_timeout=5000", nil
+}
+
+// backup copies the main database of src over that of dst
+func backup(dst, src *sql.DB) error {
+	ctx := context.Background()
+
+	dc, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dc.Close()
+
+	sc, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	return dc.Raw(func(d interface{}) error {
+		return sc.Raw(func(s interface{}) error {
+			b, err := d.(*sqlite3.SQLiteConn).Backup("main", s.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+
+			return b.Finish()
+		})
+	})
+}
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	query "github.com/nboughton/go-sqgenlite"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Kinds of statistic held in the stats cache
+const (
+	statFrequencies = "frequencies"
+	statGaps        = "gaps"
+	statPairs       = "pairs"
+)
+
+// cacheLimit is the most statistics kept in the stats cache. The oldest are removed
+// to make room for new ones.
+const cacheLimit = 500
+
+// cacheKey identifies a cached statistic. Arg holds any parameter of the statistic
+// other than the filter, such as the number of pairs returned by CoOccurrence.
+type cacheKey struct {
+	Kind   string
+	Filter Filter
+	Arg    int
+}
+
+// newCacheKey returns the key of the statistic kind of the draws matched by f. The
+// filter is normalised so that filters matching the same draws share a key: lists
+// are sorted with empty lists treated as nil, dates are held in UTC and the end date
+// is truncated to the day and moved back to the last stored draw if it's later.
+func (db *AppDB) newCacheKey(kind string, f Filter, arg int) cacheKey {
+	f.Machines = sortedStrings(f.Machines)
+	f.Sets = sortedInts(f.Sets)
+	f.Draws = sortedInts(f.Draws)
+	f.Events = sortedStrings(f.Events)
+	f.HasAll = sortedInts(f.HasAll)
+	f.HasAny = sortedInts(f.HasAny)
+
+	if len(f.Days) == 0 {
+		f.Days = nil
+	} else {
+		f.Days = append([]time.Weekday{}, f.Days...)
+		sort.Slice(f.Days, func(i, j int) bool { return f.Days[i] < f.Days[j] })
+	}
+
+	if !f.Begin.IsZero() {
+		f.Begin = f.Begin.UTC()
+	}
+	if !f.End.IsZero() {
+		f.End = f.End.UTC().Truncate(24 * time.Hour)
+		if last, err := db.lastDate(f.Game); err == nil && !last.IsZero() && f.End.After(last) {
+			f.End = last
+		}
+	}
+
+	return cacheKey{Kind: kind, Filter: f, Arg: arg}
+}
+
+// lastDate returns the date of the last stored draw of game, or of any game if game
+// is empty
+func (db *AppDB) lastDate(game string) (time.Time, error) {
+	q := query.NewQuery().Select("results", "MAX(date)")
+	if game != "" {
+		q.Where("game = ?", game)
+	}
+
+	var last interface{}
+	if err := db.QueryRow(q.SQL.String(), q.Args...).Scan(&last); err != nil {
+		return time.Time{}, err
+	}
+
+	return scanTime(last).UTC(), nil
+}
+
+func sortedStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	s = append([]string{}, s...)
+	sort.Strings(s)
+	return s
+}
+
+func sortedInts(s []int) []int {
+	if len(s) == 0 {
+		return nil
+	}
+	s = append([]int{}, s...)
+	sort.Ints(s)
+	return s
+}
+
+func (k cacheKey) signature() (string, error) {
+	b, err := json.Marshal(k)
+	if err != nil {
+		return "", err
+	}
+
+	return hashBytes(b), nil
+}
+
+// frequencies is the cached form of the sets returned by Frequencies
+type frequencies struct {
+	Balls lotto.FrequencySet
+	Bonus lotto.FrequencySet
+}
+
+// cached reads the statistic for k into v, returning false if it isn't in the cache
+func (db *AppDB) cached(k cacheKey, v interface{}) bool {
+	sig, err := k.signature()
+	if err != nil {
+		return false
+	}
+
+	q := query.NewQuery().
+		Select("stats_cache", "data").
+		Where("game = ? AND kind = ? AND signature = ?", k.Filter.Game, k.Kind, sig)
+
+	data := ""
+	if err := db.QueryRow(q.SQL.String(), q.Args...).Scan(&data); err != nil {
+		if err != sql.ErrNoRows {
+			log.Println(err)
+		}
+		return false
+	}
+
+	return json.Unmarshal([]byte(data), v) == nil
+}
+
+// cache stores v as the statistic for k. Failing to cache a statistic isn't fatal
+// so errors are only logged.
+func (db *AppDB) cache(k cacheKey, v interface{}) {
+	if db.opts.ReadOnly {
+		return
+	}
+
+	sig, err := k.signature()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	f, err := json.Marshal(k.Filter)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	q := query.NewQuery().
+		Insert("stats_cache", []string{"game", "kind", "signature", "filter", "begin_date", "end_date", "data", "created_at"},
+			k.Filter.Game, k.Kind, sig, string(f), cacheDate(k.Filter.Begin), cacheDate(k.Filter.End), string(data), time.Now().UTC()).
+		Append("ON CONFLICT (game, kind, signature) DO UPDATE SET data = excluded.data, created_at = excluded.created_at")
+
+	if _, err := db.Exec(q.SQL.String(), q.Args...); err != nil {
+		log.Println(err)
+		return
+	}
+
+	// Evict the oldest statistics beyond the limit
+	d := query.NewQuery().
+		Delete("stats_cache").
+		Where("created_at < (SELECT created_at FROM stats_cache ORDER BY created_at DESC LIMIT 1 OFFSET ?)", cacheLimit-1)
+
+	if _, err := db.Exec(d.SQL.String(), d.Args...); err != nil {
+		log.Println(err)
+	}
+}
+
+// cacheDate returns the value stored for a filter date, which is NULL if the filter
+// isn't bounded by it
+func cacheDate(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(fmtSqlite)
+}
+
+// invalidate removes every cached statistic whose filter matches any of results.
+// Statistics for filters that can't match a changed draw are left in place. Only the
+// filters of statistics for the same games and dates as results are read.
+func (db *AppDB) invalidate(results ...lotto.Result) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	var (
+		games       = make(map[string]bool)
+		args        = []interface{}{""}
+		first, last = results[0].Date, results[0].Date
+	)
+	for _, res := range results {
+		if !games[res.Game] {
+			games[res.Game] = true
+			args = append(args, res.Game)
+		}
+		if res.Date.Before(first) {
+			first = res.Date
+		}
+		if res.Date.After(last) {
+			last = res.Date
+		}
+	}
+	args = append(args, last.Format(fmtSqlite), first.Format(fmtSqlite))
+
+	q := query.NewQuery().
+		Select("stats_cache", "game", "kind", "signature", "filter").
+		Where(groupOR("game", len(games)+1)+" AND (begin_date IS NULL OR begin_date <= ?) AND (end_date IS NULL OR end_date >= ?)", args...)
+	rows, err := db.Query(q.SQL.String(), q.Args...)
+	if err != nil {
+		return err
+	}
+
+	var stale [][]interface{}
+	for rows.Next() {
+		var (
+			game, kind, sig, data string
+			f                     Filter
+		)
+		if err := rows.Scan(&game, &kind, &sig, &data); err != nil {
+			rows.Close()
+			return err
+		}
+
+		// Drop anything that can't be read back rather than risk serving it
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			stale = append(stale, []interface{}{game, kind, sig})
+			continue
+		}
+
+		for _, res := range results {
+			if f.Match(res) {
+				stale = append(stale, []interface{}{game, kind, sig})
+				break
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, args := range stale {
+		d := query.NewQuery().Delete("stats_cache").Where("game = ? AND kind = ? AND signature = ?", args...)
+		if _, err := db.Exec(d.SQL.String(), d.Args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClearStats empties the stats cache
+func (db *AppDB) ClearStats() error {
+	_, err := db.Exec(query.NewQuery().Delete("stats_cache").SQL.String())
+	return err
+}
@@ -1,11 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
@@ -15,79 +18,520 @@ import (
 )
 
 var (
-	sqlPragmas = "PRAGMA journal_mode=WAL;	PRAGMA busy_timeout=5000"
-	sqlSchema  = "CREATE TABLE IF NOT EXISTS results (id INTEGER PRIMARY KEY AUTOINCREMENT, date DATETIME, bset INT, bmac TEXT,	ball1 INT, ball2 INT, ball3 INT, ball4 INT, ball5 INT, ball6 INT, bonus INT)"
-	allFields  = []string{"date", "bset", "bmac", "ball1", "ball2", "ball3", "ball4", "ball5", "ball6", "bonus"}
-	fmtSqlite  = "2006-01-02 15:04:05-07:00"
+	sqlParams = "_journal_mode=WAL&_busy_timeout=5000" // Set on every connection
+	sqlSchema = "CREATE TABLE IF NOT EXISTS results (id INTEGER PRIMARY KEY AUTOINCREMENT, date DATETIME, bset INT, bmac TEXT,	ball1 INT, ball2 INT, ball3 INT, ball4 INT, ball5 INT, ball6 INT, bonus INT)"
+	sqlIndex  = "CREATE UNIQUE INDEX IF NOT EXISTS results_draw ON results (game, date, draw)"
+	allFields = []string{"game", "draw", "date", "bset", "bmac", "ball1", "ball2", "ball3", "ball4", "ball5", "ball6", "bonus", "source", "fetched_at", "source_hash", "parser_version", "drawn_at", "events"}
+	fmtSqlite = "2006-01-02 15:04:05-07:00"
+
+	// migrations are applied in order to bring older databases up to date with the
+	// current schema. The index of each migration + 1 is stored as the user_version.
+	sqlMigrations = []string{
+		"ALTER TABLE results ADD COLUMN game TEXT NOT NULL DEFAULT 'lotto'; ALTER TABLE results ADD COLUMN draw INT NOT NULL DEFAULT 0",
+		sqlDrawBalls,
+		sqlProvenance,
+		sqlAudit,
+		sqlStatsCache,
+		sqlDrawInfo,
+		sqlStatsCacheDates,
+	}
+
+	// The date range of each cached statistic's filter is kept alongside it so that
+	// only the statistics that could match a changed draw need their filter read.
+	// NULL is an unbounded range.
+	sqlStatsCacheDates = `ALTER TABLE stats_cache ADD COLUMN begin_date DATETIME;
+		ALTER TABLE stats_cache ADD COLUMN end_date DATETIME;
+		CREATE INDEX stats_cache_created ON stats_cache (created_at)`
+
+	// Official draw numbers are indexed so that draws can be looked up by them.
+	// Events are held as a comma separated list.
+	sqlDrawInfo = `ALTER TABLE results ADD COLUMN drawn_at DATETIME;
+		ALTER TABLE results ADD COLUMN events TEXT NOT NULL DEFAULT '';
+		CREATE INDEX results_draw_number ON results (game, draw)`
+
+	// stats_cache holds aggregate statistics keyed by game, kind and a signature of
+	// the filter that produced them. The filter is kept so that entries can be
+	// invalidated when a draw they match changes.
+	sqlStatsCache = `CREATE TABLE stats_cache (game TEXT NOT NULL, kind TEXT NOT NULL, signature TEXT NOT NULL, filter TEXT NOT NULL, data TEXT NOT NULL, created_at DATETIME NOT NULL, PRIMARY KEY (game, kind, signature))`
+
+	sqlAudit = `CREATE TABLE annotations (id INTEGER PRIMARY KEY AUTOINCREMENT, game TEXT NOT NULL, date DATETIME NOT NULL, note TEXT NOT NULL, author TEXT NOT NULL, created_at DATETIME NOT NULL);
+		CREATE INDEX annotations_draw ON annotations (game, date);
+		CREATE TABLE audit (id INTEGER PRIMARY KEY AUTOINCREMENT, game TEXT NOT NULL, date DATETIME NOT NULL, field TEXT NOT NULL, old_value TEXT NOT NULL, new_value TEXT NOT NULL, author TEXT NOT NULL, changed_at DATETIME NOT NULL);
+		CREATE INDEX audit_draw ON audit (game, date)`
+
+	sqlProvenance = `ALTER TABLE results ADD COLUMN source TEXT NOT NULL DEFAULT '';
+		ALTER TABLE results ADD COLUMN fetched_at DATETIME;
+		ALTER TABLE results ADD COLUMN source_hash TEXT NOT NULL DEFAULT '';
+		ALTER TABLE results ADD COLUMN parser_version TEXT NOT NULL DEFAULT ''`
+
+	// draw_balls holds one row per ball drawn so that frequencies and the like can
+	// be counted in SQL. Triggers keep it in sync with results.
+	sqlDrawBalls = `CREATE TABLE draw_balls (draw_id INTEGER NOT NULL, position INT NOT NULL, ball INT NOT NULL, is_bonus INT NOT NULL DEFAULT 0, PRIMARY KEY (draw_id, position));
+		CREATE INDEX draw_balls_ball ON draw_balls (ball, is_bonus);
+		INSERT INTO draw_balls (draw_id, position, ball, is_bonus)
+			SELECT id, 1, ball1, 0 FROM results UNION ALL SELECT id, 2, ball2, 0 FROM results UNION ALL
+			SELECT id, 3, ball3, 0 FROM results UNION ALL SELECT id, 4, ball4, 0 FROM results UNION ALL
+			SELECT id, 5, ball5, 0 FROM results UNION ALL SELECT id, 6, ball6, 0 FROM results UNION ALL
+			SELECT id, 7, bonus, 1 FROM results;
+		CREATE TRIGGER results_balls_insert AFTER INSERT ON results BEGIN
+			INSERT INTO draw_balls (draw_id, position, ball, is_bonus) VALUES
+				(new.id, 1, new.ball1, 0), (new.id, 2, new.ball2, 0), (new.id, 3, new.ball3, 0),
+				(new.id, 4, new.ball4, 0), (new.id, 5, new.ball5, 0), (new.id, 6, new.ball6, 0),
+				(new.id, 7, new.bonus, 1);
+		END;
+		CREATE TRIGGER results_balls_update AFTER UPDATE OF ball1, ball2, ball3, ball4, ball5, ball6, bonus ON results BEGIN
+			DELETE FROM draw_balls WHERE draw_id = old.id;
+			INSERT INTO draw_balls (draw_id, position, ball, is_bonus) VALUES
+				(new.id, 1, new.ball1, 0), (new.id, 2, new.ball2, 0), (new.id, 3, new.ball3, 0),
+				(new.id, 4, new.ball4, 0), (new.id, 5, new.ball5, 0), (new.id, 6, new.ball6, 0),
+				(new.id, 7, new.bonus, 1);
+		END;
+		CREATE TRIGGER results_balls_delete AFTER DELETE ON results BEGIN
+			DELETE FROM draw_balls WHERE draw_id = old.id;
+		END;`
 )
 
-// AppDB is a wrapper for *sql.DB so I can extend it by adding my own methods
+// sqlite is the default dialect
+var sqlite = &dialect{
+	driver:     "sqlite3",
+	schema:     sqlSchema,
+	migrations: sqlMigrations,
+	hasResults: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'results'",
+	version:    "PRAGMA user_version",
+	setVersion: "PRAGMA user_version = %d",
+	groupIDs:   "GROUP_CONCAT(id)",
+	weekday:    "CAST(strftime('%%w', %s) AS INTEGER)",
+	contains:   "INSTR(%s, %s) > 0",
+}
+
+// AppDB is a wrapper for *sql.DB so I can extend it by adding my own methods. The
+// embedded *sql.DB is used for writes and read for queries so that readers aren't
+// held up behind a write.
 type AppDB struct {
 	*sql.DB
+	read    *sql.DB
+	dialect *dialect
+	path    string
+	opts    Options
+	temp    bool // path is a private copy that's removed on Close
+}
+
+// Options control how a database is opened
+type Options struct {
+	ReadOnly     bool   // Refuse any writes
+	SnapshotDir  string // Directory that snapshots are written to
+	Keep         int    // Number of snapshots kept in SnapshotDir, 0 keeps every snapshot
+	AutoSnapshot bool   // Take a snapshot before migrating or fully resyncing a SQLite database
 }
 
-// Connect returns a DB connection wrapper
-func Connect(path string) *AppDB {
+// ErrReadOnly is returned when writing to a database opened read-only
+var ErrReadOnly = errors.New("database is opened read-only")
+
+// ErrManual is returned by Upsert for a result that wasn't stored because its draw
+// has been corrected by hand
+var ErrManual = errors.New("draw has been corrected by hand")
+
+// Connect returns a DB connection wrapper. Writes go through a single connection
+// while reads share a pool of read-only connections, which WAL mode lets run
+// alongside the writer. A database opened read-only must already exist with an up
+// to date schema.
+func Connect(path string, opts Options) *AppDB {
+	if opts.ReadOnly {
+		return newReadOnlyAppDB(openSqliteReader(path), sqlite, path, opts)
+	}
+
 	// I don't care where you want your database. I'm going to ensure that it's there
 	dir, _ := filepath.Split(path)
 	if err := os.MkdirAll(dir, 0770); err != nil {
 		log.Fatal(err)
 	}
 
-	// Connect to the database
-	db, err := sql.Open("sqlite3", path)
+	// Connect to the database. The driver resets the journal mode of every new
+	// connection so WAL has to be set as a connection parameter rather than a PRAGMA.
+	db, err := sql.Open(sqlite.driver, path+"?"+sqlParams)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Disable connection pooling
+	// SQLite allows one writer at a time
 	db.SetMaxOpenConns(1)
-	db.SetMaxIdleConns(0)
 
-	// Set PRAGMAs
-	if _, err := db.Exec(sqlPragmas); err != nil {
-		log.Fatal(err.Error())
+	if err := db.Ping(); err != nil {
+		log.Fatal(err)
 	}
 
-	// Create DB schema if it doesn't exist
-	if _, err := db.Exec(sqlSchema); err != nil {
+	return newAppDB(db, openSqliteReader(path), sqlite, path, opts)
+}
+
+// openSqliteReader opens a pool of read-only connections to the database at path.
+// Databases that aren't in WAL mode, such as backups, are opened in the journal
+// mode they already have as a read-only connection can't change it.
+func openSqliteReader(path string) *sql.DB {
+	params, err := journalParams(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := sql.Open(sqlite.driver, "file:"+path+"?mode=ro&"+params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db.SetMaxOpenConns(runtime.NumCPU())
+
+	if err := db.Ping(); err != nil {
 		log.Fatal(err)
 	}
 
-	return &AppDB{db}
+	return db
 }
 
-// Update scrapes the archive site and adds newer records until
-// an existing record is found.
-func (db *AppDB) Update() error {
-	q := query.NewQuery().Insert("results", allFields)
+// newAppDB wraps db, creating the schema and applying any outstanding migrations.
+// Queries are run against read.
+func newAppDB(db, read *sql.DB, d *dialect, path string, opts Options) *AppDB {
+	appDB := &AppDB{DB: db, read: read, dialect: d, path: path, opts: opts}
 
-	stmt, err := db.Prepare(q.SQL.String())
+	// Databases that already hold results are worth a snapshot before migrating
+	existed := 0
+	if err := db.QueryRow(d.hasResults).Scan(&existed); err != nil {
+		log.Fatal(err)
+	}
+
+	// Create DB schema if it doesn't exist
+	if _, err := db.Exec(d.schema); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := appDB.migrate(existed > 0); err != nil {
+		log.Fatal(err)
+	}
+
+	// Older databases may already hold duplicate draws, in which case the unique
+	// index can't be created until they've been merged
+	dupes, err := appDB.duplicates()
 	if err != nil {
+		log.Fatal(err)
+	}
+	if len(dupes) > 0 {
+		log.Printf("%d draws are duplicated, run \"stalotto db dedupe\" to merge them\n", len(dupes))
+	} else if _, err := db.Exec(sqlIndex); err != nil {
+		log.Fatal(err)
+	}
+
+	return appDB
+}
+
+// newReadOnlyAppDB wraps db without making any changes to it
+func newReadOnlyAppDB(db *sql.DB, d *dialect, path string, opts Options) *AppDB {
+	appDB := &AppDB{DB: db, read: db, dialect: d, path: path, opts: opts}
+
+	version := 0
+	if err := db.QueryRow(d.version).Scan(&version); err != nil {
+		log.Fatal(err)
+	}
+	if version < len(d.migrations) {
+		log.Fatal("The database schema is out of date, open it once without --read-only to migrate it")
+	}
+
+	return appDB
+}
+
+// Close closes the writer and reader connections
+func (db *AppDB) Close() error {
+	err := db.DB.Close()
+	if db.read != db.DB {
+		if rerr := db.read.Close(); err == nil {
+			err = rerr
+		}
+	}
+
+	if db.temp {
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Remove(db.path + suffix)
+		}
+	}
+
+	return err
+}
+
+// migrate applies any migrations that haven't yet been run against the database. If
+// snapshot is set the database is snapshotted first when automatic snapshots are
+// enabled and there are migrations to apply.
+func (db *AppDB) migrate(snapshot bool) error {
+	version := 0
+	if err := db.QueryRow(db.dialect.version).Scan(&version); err != nil {
 		return err
 	}
 
-	for res := range Scrape() {
-		if db.Exists(res.Date) {
-			return fmt.Errorf("update done")
+	migrations := db.dialect.migrations
+	if snapshot && version < len(migrations) {
+		if err := db.autoSnapshot("migration"); err != nil {
+			return err
+		}
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %s", i+1, err)
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf(db.dialect.setVersion, i+1)); err != nil {
+			tx.Rollback()
+			return err
 		}
 
-		if _, err := stmt.Exec(res.Date, res.Set, res.Machine, res.Balls[0], res.Balls[1], res.Balls[2], res.Balls[3], res.Balls[4], res.Balls[5], res.Bonus); err != nil {
+		if err := tx.Commit(); err != nil {
 			return err
 		}
-		log.Printf("Inserted: %+v \n", res)
 	}
 
 	return nil
 }
 
-// Exists returns true if a record with t timestamp exists
-func (db *AppDB) Exists(t time.Time) bool {
-	if _, err := db.Result(t); err != nil {
+// Missing returns the scheduled draw dates of game between begin and end that have
+// no record in the database
+func (db *AppDB) Missing(game lotto.Game, begin, end time.Time) ([]time.Time, error) {
+	q := query.NewQuery().
+		Select("results", "date").
+		Where("game = ? AND date BETWEEN ? AND ?", game.Name, begin.Format(fmtSqlite), end.Format(fmtSqlite))
+
+	rows, err := db.Query(q.SQL.String(), q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	have := make(map[string]bool)
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		have[t.UTC().Format("2006-01-02")] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []time.Time
+	for _, d := range game.DrawDates(begin, end) {
+		if !have[d.Format("2006-01-02")] {
+			missing = append(missing, d)
+		}
+	}
+
+	return missing, nil
+}
+
+// Upsert inserts a result or, if a result with the same game, date and draw number
+// already exists, replaces its values. Draws that have been corrected by hand are
+// never replaced, even if the correction changed the draw number, and ErrManual is
+// returned for them instead.
+func (db *AppDB) Upsert(res lotto.Result) error {
+	if err := validate(res); err != nil {
+		return err
+	}
+
+	if manual, err := db.manual(res.Game, res.Date); err != nil {
+		return err
+	} else if manual {
+		return ErrManual
+	}
+
+	if err := db.matchDraw(&res); err != nil {
+		return err
+	}
+
+	// Any stored values are needed to invalidate the stats they contributed to. Draw
+	// metadata that only some sources provide is kept if res doesn't have it.
+	var changed []lotto.Result
+	if old, err := db.draw(res.Game, res.Date); err == nil {
+		keepDrawInfo(&res, old)
+		changed = append(changed, old)
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	changed = append(changed, res)
+
+	q := query.NewQuery().
+		Insert("results", allFields, resultArgs(res)...).
+		Append("ON CONFLICT (game, date, draw) DO UPDATE SET").
+		Append(upsertSet(allFields[3:])).
+		Append("WHERE results.source <> ?", lotto.SourceManual)
+
+	r, err := db.Exec(q.SQL.String(), q.Args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrManual
+	}
+
+	return db.invalidate(changed...)
+}
+
+// draw retrieves the stored result for game on date
+func (db *AppDB) draw(game string, date time.Time) (lotto.Result, error) {
+	q := query.NewQuery().
+		Select("results", allFields...).
+		Where("game = ? AND date = ?", game, date.Format(fmtSqlite)).
+		Append("LIMIT 1")
+
+	return scanResult(db.QueryRow(q.SQL.String(), q.Args...))
+}
+
+// manual returns true if the draw of game on date has been corrected by hand
+func (db *AppDB) manual(game string, date time.Time) (bool, error) {
+	q := query.NewQuery().
+		Select("results", "COUNT(*)").
+		Where("game = ? AND date = ? AND source = ?", game, date.Format(fmtSqlite), lotto.SourceManual)
+
+	var n int
+	err := db.QueryRow(q.SQL.String(), q.Args...).Scan(&n)
+	return n > 0, err
+}
+
+// keepDrawInfo copies the draw time and events of old into res where res has none
+func keepDrawInfo(res *lotto.Result, old lotto.Result) {
+	if res.DrawnAt.IsZero() {
+		res.DrawnAt = old.DrawnAt
+	}
+	if len(res.Events) == 0 {
+		res.Events = old.Events
+	}
+}
+
+// invalidError is returned by validate for a result that breaks the rules of its
+// game
+type invalidError struct {
+	error
+}
+
+// isInvalid returns true if err was returned for an invalid result, which sources
+// are expected to produce now and then and which should be skipped rather than end
+// an update
+func isInvalid(err error) bool {
+	_, ok := err.(invalidError)
+	return ok
+}
+
+// validate checks res against the rules of its game
+func validate(res lotto.Result) error {
+	game, err := lotto.GameByName(res.Game)
+	if err != nil {
+		return invalidError{err}
+	}
+
+	if err := res.Validate(game); err != nil {
+		return invalidError{err}
+	}
+
+	return nil
+}
+
+// matchDraw reconciles draw numbers between sources that know them and sources that
+// don't. A result without a draw number takes the number of the stored result for
+// the same date, and a stored result without one is given the number of res unless
+// it was corrected by hand.
+func (db *AppDB) matchDraw(res *lotto.Result) error {
+	date := res.Date.Format(fmtSqlite)
+
+	if res.Draw == 0 {
+		q := query.NewQuery().
+			Select("results", "COALESCE(MAX(draw), 0)").
+			Where("game = ? AND date = ?", res.Game, date)
+
+		return db.QueryRow(q.SQL.String(), q.Args...).Scan(&res.Draw)
+	}
+
+	q := query.NewQuery().
+		Update("results", []string{"draw"}, res.Draw).
+		Where("game = ? AND date = ? AND draw = 0 AND source <> ?", res.Game, date, lotto.SourceManual).
+		Append("AND NOT EXISTS (SELECT 1 FROM results WHERE game = ? AND date = ? AND draw = ?)", res.Game, date, res.Draw)
+
+	_, err := db.Exec(q.SQL.String(), q.Args...)
+	return err
+}
+
+// scanner is satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanResult scans a row selected with allFields into a Result. Any extra
+// destinations are scanned from the columns selected before allFields.
+func scanResult(s scanner, extra ...interface{}) (lotto.Result, error) {
+	var (
+		res              = lotto.NewResult()
+		fetched, drawnAt sql.NullTime
+		events           string
+		src              = &res.Provenance
+	)
+
+	dest := append(extra, &res.Game, &res.Draw, &res.Date, &res.Set, &res.Machine, &res.Balls[0], &res.Balls[1], &res.Balls[2], &res.Balls[3], &res.Balls[4], &res.Balls[5], &res.Bonus, &src.Source, &fetched, &src.Hash, &src.ParserVersion, &drawnAt, &events)
+	err := s.Scan(dest...)
+
+	// PostgreSQL returns times in the session's time zone, which would move dates
+	// formatted without one to another day
+	res.Date, src.FetchedAt, res.DrawnAt = res.Date.UTC(), utc(fetched.Time), utc(drawnAt.Time)
+	if events != "" {
+		res.Events = strings.Split(events, ",")
+	}
+
+	return res, err
+}
+
+// utc returns t in UTC, leaving the zero time as it is
+func utc(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC()
+}
+
+// resultArgs returns the values of res in the same order as allFields
+func resultArgs(res lotto.Result) []interface{} {
+	var fetched, drawnAt interface{}
+	if !res.Provenance.FetchedAt.IsZero() {
+		fetched = res.Provenance.FetchedAt
+	}
+	if !res.DrawnAt.IsZero() {
+		drawnAt = res.DrawnAt
+	}
+
+	return []interface{}{res.Game, res.Draw, res.Date, res.Set, res.Machine, res.Balls[0], res.Balls[1], res.Balls[2], res.Balls[3], res.Balls[4], res.Balls[5], res.Bonus, res.Provenance.Source, fetched, res.Provenance.Hash, res.Provenance.ParserVersion, drawnAt, strings.Join(res.Events, ",")}
+}
+
+func upsertSet(fields []string) string {
+	slc := make([]string, len(fields))
+	for i, f := range fields {
+		slc[i] = fmt.Sprintf("%s = excluded.%s", f, f)
+	}
+	return strings.Join(slc, ", ")
+}
+
+// Exists returns true if a record for game with t timestamp exists
+func (db *AppDB) Exists(game string, t time.Time) bool {
+	q := query.NewQuery().
+		Select("results", "COUNT(id)").
+		Where("game = ? AND date = ?", game, t.Format(fmtSqlite))
+
+	n := 0
+	if err := db.QueryRow(q.SQL.String(), q.Args...).Scan(&n); err != nil {
 		return false
 	}
 
-	return true
+	return n > 0
 }
 
 // Result retrieves a single record
@@ -101,8 +545,7 @@ func (db *AppDB) Result(t time.Time) (lotto.Result, error) {
 		return lotto.Result{}, err
 	}
 
-	res := lotto.NewResult()
-	return res, stmt.QueryRow(q.Args...).Scan(&res.Date, &res.Set, &res.Machine, &res.Balls[0], &res.Balls[1], &res.Balls[2], &res.Balls[3], &res.Balls[4], &res.Balls[5], &res.Bonus)
+	return scanResult(stmt.QueryRow(q.Args...))
 }
 
 func groupOR(field string, vals int) string {
@@ -113,76 +556,66 @@ func groupOR(field string, vals int) string {
 	return "(" + strings.Join(slc, " OR ") + ")"
 }
 
-// Results returns a channel of records
-func (db *AppDB) Results(begin, end time.Time, machines []string, sets []int, orderDesc bool) <-chan lotto.Result {
-	c := make(chan lotto.Result)
+// Results streams records matched by f over the first channel returned. The second
+// channel receives at most one error, reporting an invalid filter or a failed query
+// or scan, and is closed once the results channel has been closed. Cancelling ctx
+// stops the query and closes both channels so that consumers can stop reading at
+// any point.
+func (db *AppDB) Results(ctx context.Context, f Filter) (<-chan lotto.Result, <-chan error) {
+	c, errc := make(chan lotto.Result), make(chan error, 1)
 
 	go func() {
+		defer close(errc)
 		defer close(c)
 
-		q := query.NewQuery().
-			Select("results", allFields...).
-			Where("date BETWEEN ? AND ?", begin.Format(fmtSqlite), end.Format(fmtSqlite))
-
-		if len(machines) > 0 {
-			q.Append(fmt.Sprintf("AND %s", groupOR("bmac", len(machines))))
-			for _, m := range machines {
-				q.Args = append(q.Args, m)
-			}
-		}
-
-		if len(sets) > 0 {
-			q.Append(fmt.Sprintf("AND %s", groupOR("bset", len(sets))))
-			for _, s := range sets {
-				q.Args = append(q.Args, s)
-			}
-		}
-
-		q.Order("date")
-
-		if orderDesc {
-			q.Append("DESC")
-		}
-
-		stmt, err := db.Prepare(q.SQL.String())
-		if err != nil {
-			log.Println(err)
+		if err := f.Validate(); err != nil {
+			errc <- err
 			return
 		}
 
-		rows, err := stmt.Query(q.Args...)
+		q := f.order(f.where(query.NewQuery().Select("results", allFields...), db.dialect))
+
+		rows, err := db.QueryContext(ctx, q.SQL.String(), q.Args...)
 		if err != nil {
-			log.Println(err)
+			errc <- err
 			return
 		}
+		defer rows.Close()
 
 		for rows.Next() {
-			res := lotto.NewResult()
-			if err := rows.Scan(&res.Date, &res.Set, &res.Machine, &res.Balls[0], &res.Balls[1], &res.Balls[2], &res.Balls[3], &res.Balls[4], &res.Balls[5], &res.Bonus); err != nil {
-				log.Println(err)
-				continue
+			res, err := scanResult(rows)
+			if err != nil {
+				errc <- err
+				return
 			}
 
-			c <- res
+			select {
+			case c <- res:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			errc <- err
 		}
 	}()
 
-	return c
+	return c, errc
 }
 
-// Machines returns the distinct machine names constrained by date and sets
-func (db *AppDB) Machines(begin time.Time, end time.Time, sets []int) ([]string, error) {
-	q := query.NewQuery().
-		Select("results", "DISTINCT(bmac)").
-		Where("date BETWEEN ? AND ?", begin.Format(fmtSqlite), end.Format(fmtSqlite))
+// matching restricts q to the draws matched by f. alias is the name results has been
+// given in q.
+func (db *AppDB) matching(q *query.Query, f Filter, alias string) *query.Query {
+	sub := f.ids(db.dialect)
+	return q.Where(fmt.Sprintf("%s.id IN (%s)", alias, sub.SQL.String()), sub.Args...)
+}
 
-	if len(sets) > 0 {
-		q.Append(fmt.Sprintf("AND %s", groupOR("bset", len(sets))))
-		for _, s := range sets {
-			q.Args = append(q.Args, s)
-		}
-	}
-	q.Order("bmac")
+// Machines returns the distinct machine names in the draws matched by f
+func (db *AppDB) Machines(f Filter) ([]string, error) {
+	q := db.matching(query.NewQuery().Select("results", "DISTINCT(bmac)"), f, "results").
+		Order("bmac")
 
 	stmt, err := db.Prepare(q.SQL.String())
 	if err != nil {
@@ -204,19 +637,10 @@ func (db *AppDB) Machines(begin time.Time, end time.Time, sets []int) ([]string,
 	return r, nil
 }
 
-// Sets returns the distinct sets constrained by date and machines
-func (db *AppDB) Sets(begin time.Time, end time.Time, machines []string) ([]int, error) {
-	q := query.NewQuery().
-		Select("results", "DISTINCT(bset)").
-		Where("date BETWEEN ? AND ?", begin.Format(fmtSqlite), end.Format(fmtSqlite))
-
-	if len(machines) > 0 {
-		q.Append(fmt.Sprintf("AND %s", groupOR("bmac", len(machines))))
-		for _, m := range machines {
-			q.Args = append(q.Args, m)
-		}
-	}
-	q.Order("bset")
+// Sets returns the distinct sets in the draws matched by f
+func (db *AppDB) Sets(f Filter) ([]int, error) {
+	q := db.matching(query.NewQuery().Select("results", "DISTINCT(bset)"), f, "results").
+		Order("bset")
 
 	stmt, err := db.Prepare(q.SQL.String())
 	if err != nil {
@@ -245,13 +669,12 @@ func (db *AppDB) LastDraw() (lotto.Result, error) {
 		Order("date").
 		Append("DESC LIMIT 1")
 
-	var res lotto.Result
 	stmt, err := db.Prepare(q.SQL.String())
 	if err != nil {
-		return res, err
+		return lotto.NewResult(), err
 	}
 
-	return res, stmt.QueryRow().Scan(&res.Date, &res.Set, &res.Machine, &res.Balls[0], &res.Balls[1], &res.Balls[2], &res.Balls[3], &res.Balls[4], &res.Balls[5], &res.Bonus)
+	return scanResult(stmt.QueryRow())
 }
 
 // DataRange retrieves the first and last record dates
@@ -263,14 +686,29 @@ func (db *AppDB) DataRange() (time.Time, time.Time, error) {
 		return time.Now(), time.Now(), err
 	}
 
-	first, last := "", ""
+	var first, last interface{}
 	if err := stmt.QueryRow().Scan(&first, &last); err != nil {
 		return time.Now(), time.Now(), err
 	}
 
-	f, _ := time.Parse(fmtSqlite, first)
-	l, _ := time.Parse(fmtSqlite, last)
-	return f, l, nil
+	return scanTime(first), scanTime(last), nil
+}
+
+// scanTime converts a date read from an aggregate, which SQLite returns as a string
+// and PostgreSQL as a time.Time, into a time.Time
+func scanTime(v interface{}) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case []byte:
+		return scanTime(string(t))
+	case string:
+		if p, err := time.Parse(fmtSqlite, t); err == nil {
+			return p
+		}
+	}
+
+	return time.Time{}
 }
 
 // MacSetFreq provides a struct for recording the frequency of a machine/set combination in draws
@@ -280,11 +718,9 @@ type MacSetFreq struct {
 	Freq    int
 }
 
-// MachineSetFreq returns date constrained set displaying which combinations of machine/set have been drawn and how many times
-func (db *AppDB) MachineSetFreq(begin time.Time, end time.Time) ([]MacSetFreq, error) {
-	q := query.NewQuery().
-		Select("results", "bmac", "bset", "COUNT(bmac) as bcount").
-		Where("date BETWEEN ? AND ?", begin.Format(fmtSqlite), end.Format(fmtSqlite)).
+// MachineSetFreq returns the combinations of machine/set in the draws matched by f and how many times each has been drawn
+func (db *AppDB) MachineSetFreq(f Filter) ([]MacSetFreq, error) {
+	q := db.matching(query.NewQuery().Select("results", "bmac", "bset", "COUNT(bmac) as bcount"), f, "results").
 		Group("bmac, bset").
 		Order("bcount")
 
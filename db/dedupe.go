@@ -0,0 +1,192 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	query "github.com/nboughton/go-sqgenlite"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// duplicates returns the row ids of any draws that are stored more than once,
+// grouped by draw
+func (db *AppDB) duplicates() ([][]int64, error) {
+	q := query.NewQuery().
+		Select("results", db.dialect.groupIDs).
+		Group("game", "date", "draw").
+		Append("HAVING COUNT(id) > 1")
+
+	rows, err := db.Query(q.SQL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups [][]int64
+	for rows.Next() {
+		ids := ""
+		if err := rows.Scan(&ids); err != nil {
+			return nil, err
+		}
+
+		var group []int64
+		for _, s := range strings.Split(ids, ",") {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			group = append(group, id)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// Dedupe merges rows that share a draw identity into the earliest stored row. Any
+// values missing from that row are taken from its duplicates before they are
+// deleted. Once merged the unique index is created so that duplicates can't be
+// stored again. Dedupe returns the number of rows removed.
+func (db *AppDB) Dedupe() (int, error) {
+	groups, err := db.duplicates()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, ids := range groups {
+		n, err := db.mergeDuplicates(tx, ids)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		removed += n
+	}
+
+	if _, err := tx.Exec(sqlIndex); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return removed, db.ClearStats()
+}
+
+func (db *AppDB) mergeDuplicates(tx *sql.Tx, group []int64) (int, error) {
+	q := query.NewQuery().
+		Select("results", append([]string{"id"}, allFields...)...).
+		Where(fmt.Sprintf("id IN (%s)", placeholders(len(group))), int64Args(group)...).
+		Order("id")
+
+	rows, err := tx.Query(db.dialect.rebind(q.SQL.String()), q.Args...)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		ids []int64
+		set []lotto.Result
+	)
+	for rows.Next() {
+		var id int64
+		res, err := scanResult(rows, &id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids, set = append(ids, id), append(set, res)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) < 2 {
+		return 0, nil
+	}
+
+	keep := set[0]
+	for _, dupe := range set[1:] {
+		if !sameDraw(keep, dupe) {
+			log.Printf("Conflicting duplicates, keeping %s and dropping %s\n", keep, dupe)
+		}
+		mergeResult(&keep, dupe)
+	}
+
+	u := query.NewQuery().
+		Update("results", allFields[3:], resultArgs(keep)[3:]...).
+		Where("id = ?", ids[0])
+	if _, err := tx.Exec(db.dialect.rebind(u.SQL.String()), u.Args...); err != nil {
+		return 0, err
+	}
+
+	d := query.NewQuery().Delete("results").Where(fmt.Sprintf("id IN (%s)", placeholders(len(ids)-1)), int64Args(ids[1:])...)
+	if _, err := tx.Exec(db.dialect.rebind(d.SQL.String()), d.Args...); err != nil {
+		return 0, err
+	}
+
+	return len(ids) - 1, nil
+}
+
+// mergeResult fills any zero values in dst with the corresponding values from src
+func mergeResult(dst *lotto.Result, src lotto.Result) {
+	if dst.Machine == "" {
+		dst.Machine = src.Machine
+	}
+	if dst.Set == 0 {
+		dst.Set = src.Set
+	}
+	for i, b := range dst.Balls {
+		if b == 0 && i < len(src.Balls) {
+			dst.Balls[i] = src.Balls[i]
+		}
+	}
+	if dst.Bonus == 0 {
+		dst.Bonus = src.Bonus
+	}
+	if dst.Provenance.Source == "" {
+		dst.Provenance = src.Provenance
+	}
+	keepDrawInfo(dst, src)
+}
+
+// sameDraw returns true if a and b don't hold any conflicting non-zero values
+func sameDraw(a, b lotto.Result) bool {
+	differs := func(x, y int) bool { return x != 0 && y != 0 && x != y }
+
+	if a.Machine != "" && b.Machine != "" && a.Machine != b.Machine {
+		return false
+	}
+	if differs(a.Set, b.Set) || differs(a.Bonus, b.Bonus) {
+		return false
+	}
+	for i := range a.Balls {
+		if i < len(b.Balls) && differs(a.Balls[i], b.Balls[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func int64Args(ids []int64) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// dialect holds the SQL that differs between the databases AppDB can run against
+type dialect struct {
+	driver       string
+	schema       string
+	migrations   []string
+	hasResults   string // Query returning 1 if the results table exists, 0 if not
+	version      string // Query returning the number of migrations applied
+	setVersion   string // Format string recording the number of migrations applied
+	groupIDs     string // Aggregate returning a comma separated list of ids
+	weekday      string // Format string returning the day of the week, Sunday = 0, of a date
+	contains     string // Format string returning whether one string contains another, case sensitively
+	numberedArgs bool   // Use $1, $2... placeholders rather than ?
+}
+
+// rebind rewrites the ? placeholders generated by go-sqgenlite for the dialect
+func (d *dialect) rebind(q string) string {
+	if !d.numberedArgs {
+		return q
+	}
+
+	var (
+		b strings.Builder
+		n = 0
+	)
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Prepare rebinds query for the dialect before preparing it on a reader
+func (db *AppDB) Prepare(query string) (*sql.Stmt, error) {
+	return db.read.Prepare(db.dialect.rebind(query))
+}
+
+// Query rebinds query for the dialect before running it on a reader
+func (db *AppDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.read.Query(db.dialect.rebind(query), args...)
+}
+
+// QueryContext rebinds query for the dialect before running it on a reader
+func (db *AppDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.read.QueryContext(ctx, db.dialect.rebind(query), args...)
+}
+
+// QueryRow rebinds query for the dialect before running it on a reader
+func (db *AppDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.read.QueryRow(db.dialect.rebind(query), args...)
+}
+
+// Exec rebinds query for the dialect before running it on the writer
+func (db *AppDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if db.opts.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	return db.DB.Exec(db.dialect.rebind(query), args...)
+}
+
+// Begin starts a transaction on the writer
+func (db *AppDB) Begin() (*sql.Tx, error) {
+	if db.opts.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	return db.DB.Begin()
+}
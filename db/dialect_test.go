@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	query "github.com/nboughton/go-sqgenlite"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// postgresDSNEnv names the environment variable holding the DSN of a PostgreSQL
+// database to run the dialect tests against. The tests work in a schema of their
+// own, stalotto_test, which is dropped and recreated on each run.
+const postgresDSNEnv = "STALOTTO_TEST_POSTGRES_DSN"
+
+// TestDialects runs the same queries against SQLite and, if postgresDSNEnv is set,
+// PostgreSQL so that SQL written for one is known to work on the other
+func TestDialects(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "stalotto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		db := Connect(filepath.Join(dir, "test.db"), Options{})
+		defer db.Close()
+		testDialect(t, db)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv(postgresDSNEnv)
+		if dsn == "" {
+			t.Skip(postgresDSNEnv + " isn't set")
+		}
+
+		db := connectTestPostgres(t, dsn)
+		defer db.Close()
+		testDialect(t, db)
+	})
+}
+
+// connectTestPostgres connects to an empty stalotto_test schema of the database at
+// dsn
+func connectTestPostgres(t *testing.T, dsn string) *AppDB {
+	raw, err := sql.Open(postgres.driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+
+	if _, err := raw.Exec("DROP SCHEMA IF EXISTS stalotto_test CASCADE; CREATE SCHEMA stalotto_test"); err != nil {
+		t.Fatal(err)
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+
+	return ConnectPostgres(dsn+sep+"search_path=stalotto_test", Options{})
+}
+
+func testDialect(t *testing.T, db *AppDB) {
+	draws := []lotto.Result{
+		testResult(2516, "2019-12-04", 1, 2, 3, 4, 5, 6, 7),
+		testResult(2517, "2019-12-07", 1, 12, 13, 14, 15, 16, 17),
+		testResult(2518, "2019-12-11", 1, 22, 23, 24, 25, 26, 27),
+		testResult(2519, "2019-12-14", 31, 32, 33, 34, 35, 36, 37),
+	}
+	for _, res := range draws {
+		if err := db.Upsert(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("trigger", func(t *testing.T) {
+		if n := countBalls(t, db, 1); n != 3 {
+			t.Errorf("ball 1 is in draw_balls %d times, want 3", n)
+		}
+
+		// Changing the balls of a draw must replace its rows in draw_balls
+		res := draws[0]
+		res.Balls = []int{41, 2, 3, 4, 5, 6}
+		if err := db.Upsert(res); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := countBalls(t, db, 1); n != 2 {
+			t.Errorf("ball 1 is in draw_balls %d times after an update, want 2", n)
+		}
+		if n := countBalls(t, db, 41); n != 1 {
+			t.Errorf("ball 41 is in draw_balls %d times after an update, want 1", n)
+		}
+	})
+
+	t.Run("weekday", func(t *testing.T) {
+		got := collect(t, db, Filter{Game: lotto.GAME}.WithDays(time.Wednesday))
+		if len(got) != 2 {
+			t.Fatalf("found %d Wednesday draws, want 2", len(got))
+		}
+		for _, res := range got {
+			if res.Date.Weekday() != time.Wednesday {
+				t.Errorf("%s was drawn on a %s", res, res.Date.Weekday())
+			}
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		// Dates read back in another time zone would fall on the previous day
+		begin, end := draws[0].Date, draws[len(draws)-1].Date
+		missing, err := db.Missing(lotto.Lotto, begin, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(missing) != 0 {
+			t.Errorf("draws on %v are missing, want none", missing)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		// Statistics are counted over a subquery of the matching draw ids, which
+		// has to honour the filter's limit
+		f := Filter{Game: lotto.GAME}.WithSort(SortDate, true).WithPage(1, 0)
+
+		balls, bonus, err := db.Frequencies(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(balls.Prune()); n != 6 {
+			t.Errorf("%d balls counted in the latest draw, want 6", n)
+		}
+		if b := bonus.Prune(); len(b) != 1 || b[0].Ball != 37 {
+			t.Errorf("bonus balls counted in the latest draw are %v, want 37", b)
+		}
+	})
+
+	t.Run("gaps", func(t *testing.T) {
+		gaps, err := db.Gaps(Filter{Game: lotto.GAME})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[int]BallGap{
+			1:  {Ball: 1, Current: 1, Longest: 1},
+			31: {Ball: 31, Current: 0, Longest: 3},
+			41: {Ball: 41, Current: 3, Longest: 3},
+		}
+		for _, g := range gaps {
+			if w, ok := want[g.Ball]; ok && g != w {
+				t.Errorf("gaps for ball %d are %+v, want %+v", g.Ball, g, w)
+			}
+		}
+		// Ball 1 is in two of the draws
+		if n := len(gaps); n != 23 {
+			t.Errorf("gaps found for %d balls, want 23", n)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		res := draws[3]
+		res.Events = []string{lotto.EventMustBeWon, lotto.EventRaffle}
+		if err := db.Upsert(res); err != nil {
+			t.Fatal(err)
+		}
+
+		// Event names match exactly, as they do in Filter.Match
+		for e, want := range map[string]int{lotto.EventRaffle: 1, "must-be%": 0, "must_be_won": 0, "RAFFLE": 0} {
+			if n := len(collect(t, db, Filter{Game: lotto.GAME}.WithEvents(e))); n != want {
+				t.Errorf("found %d draws with event %q, want %d", n, e, want)
+			}
+		}
+	})
+
+	t.Run("dedupe", func(t *testing.T) {
+		// Duplicates can only be stored without the unique index, as in databases
+		// made before it was added
+		if _, err := db.Exec("DROP INDEX results_draw"); err != nil {
+			t.Fatal(err)
+		}
+
+		q := query.NewQuery().Insert("results", allFields, resultArgs(draws[1])...)
+		if _, err := db.Exec(q.SQL.String(), q.Args...); err != nil {
+			t.Fatal(err)
+		}
+
+		dupes, err := db.duplicates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dupes) != 1 || len(dupes[0]) != 2 || dupes[0][0] >= dupes[0][1] {
+			t.Fatalf("duplicates are %v, want one group of two ids in order", dupes)
+		}
+
+		n, err := db.Dedupe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("dedupe removed %d rows, want 1", n)
+		}
+		if got := collect(t, db, Filter{Game: lotto.GAME}); len(got) != len(draws) {
+			t.Errorf("%d draws stored after dedupe, want %d", len(got), len(draws))
+		}
+	})
+}
+
+func testResult(draw int, date string, balls ...int) lotto.Result {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		panic(err)
+	}
+
+	return lotto.Result{Game: lotto.GAME, Draw: draw, Date: d, Machine: "Arthur", Set: 1, Balls: balls[:6], Bonus: balls[6]}
+}
+
+// countBalls returns the number of times ball is stored in draw_balls as a main ball
+func countBalls(t *testing.T, db *AppDB, ball int) int {
+	q := query.NewQuery().
+		Select("draw_balls", "COUNT(*)").
+		Where("ball = ? AND is_bonus = 0", ball)
+
+	n := 0
+	if err := db.QueryRow(q.SQL.String(), q.Args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+// collect returns every draw matched by f
+func collect(t *testing.T, db *AppDB, f Filter) lotto.ResultSet {
+	var set lotto.ResultSet
+
+	results, errc := db.Results(context.Background(), f)
+	for res := range results {
+		set = append(set, res)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	return set
+}
@@ -0,0 +1,237 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	query "github.com/nboughton/go-sqgenlite"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Correction holds the fields of a draw to be changed by hand. Nil fields are left
+// as they are.
+type Correction struct {
+	Draw    *int
+	Machine *string
+	Set     *int
+	Balls   []int
+	Bonus   *int
+}
+
+// Change is an entry in the audit trail of a draw
+type Change struct {
+	Field  string
+	Old    string
+	New    string
+	Author string
+	At     time.Time
+}
+
+// Note is a free text annotation attached to a draw
+type Note struct {
+	Note   string
+	Author string
+	At     time.Time
+}
+
+// Correct applies c to the draw of game on date and records each changed field in
+// the audit trail. The corrected result is marked as a manual entry so that later
+// updates won't overwrite it.
+func (db *AppDB) Correct(game string, date time.Time, c Correction, author string) (lotto.Result, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return lotto.Result{}, err
+	}
+
+	old, res, err := db.correct(tx, game, date, c, author)
+	if err != nil {
+		tx.Rollback()
+		return res, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return res, err
+	}
+
+	return res, db.invalidate(old, res)
+}
+
+// correct applies c within tx, returning the result before and after correction
+func (db *AppDB) correct(tx *sql.Tx, game string, date time.Time, c Correction, author string) (lotto.Result, lotto.Result, error) {
+	q := query.NewQuery().
+		Select("results", append([]string{"id"}, allFields...)...).
+		Where("game = ? AND date = ?", game, date.Format(fmtSqlite))
+
+	var id int64
+	old, err := scanResult(tx.QueryRow(db.dialect.rebind(q.SQL.String()), q.Args...), &id)
+	if err == sql.ErrNoRows {
+		return old, old, fmt.Errorf("no %s draw on %s", game, date.Format("2006-01-02"))
+	}
+	if err != nil {
+		return old, old, err
+	}
+
+	res := old
+	res.Balls = append([]int{}, old.Balls...)
+	if c.Draw != nil {
+		res.Draw = *c.Draw
+	}
+	if c.Machine != nil {
+		res.Machine = *c.Machine
+	}
+	if c.Set != nil {
+		res.Set = *c.Set
+	}
+	if c.Balls != nil {
+		if len(c.Balls) != len(res.Balls) {
+			return old, old, fmt.Errorf("%d balls given, %d are needed", len(c.Balls), len(res.Balls))
+		}
+		copy(res.Balls, c.Balls)
+	}
+	if c.Bonus != nil {
+		res.Bonus = *c.Bonus
+	}
+
+	changes := diffResults(old, res)
+	if len(changes) == 0 {
+		return old, old, fmt.Errorf("%s is unchanged", old)
+	}
+
+	if err := validate(res); err != nil {
+		return old, old, err
+	}
+
+	res.Provenance = lotto.Provenance{Source: lotto.SourceManual, FetchedAt: time.Now().UTC()}
+	changes = append(changes, Change{Field: "source", Old: old.Provenance.Source, New: lotto.SourceManual})
+
+	if err := db.overwrite(tx, id, res, changes, author); err != nil {
+		return old, old, err
+	}
+
+	return old, res, nil
+}
+
+// overwrite replaces the values of the result stored with id by res within tx and
+// adds changes to its audit trail
+func (db *AppDB) overwrite(tx *sql.Tx, id int64, res lotto.Result, changes []Change, author string) error {
+	u := query.NewQuery().
+		Update("results", allFields[1:], resultArgs(res)[1:]...).
+		Where("id = ?", id)
+	if _, err := tx.Exec(db.dialect.rebind(u.SQL.String()), u.Args...); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, ch := range changes {
+		i := query.NewQuery().Insert("audit",
+			[]string{"game", "date", "field", "old_value", "new_value", "author", "changed_at"},
+			res.Game, res.Date, ch.Field, ch.Old, ch.New, author, now)
+		if _, err := tx.Exec(db.dialect.rebind(i.SQL.String()), i.Args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffResults returns a Change for each drawn value that differs between a and b
+func diffResults(a, b lotto.Result) []Change {
+	var changes []Change
+	add := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, Change{Field: field, Old: old, New: new})
+		}
+	}
+
+	add("draw", strconv.Itoa(a.Draw), strconv.Itoa(b.Draw))
+	add("machine", a.Machine, b.Machine)
+	add("set", strconv.Itoa(a.Set), strconv.Itoa(b.Set))
+	add("balls", joinInts(a.Balls), joinInts(b.Balls))
+	add("bonus", strconv.Itoa(a.Bonus), strconv.Itoa(b.Bonus))
+	add("drawn_at", formatDrawnAt(a.DrawnAt), formatDrawnAt(b.DrawnAt))
+	add("events", strings.Join(a.Events, ","), strings.Join(b.Events, ","))
+
+	return changes
+}
+
+func formatDrawnAt(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func joinInts(slc []int) string {
+	s := make([]string, len(slc))
+	for i, n := range slc {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// Annotate attaches note to the draw of game on date
+func (db *AppDB) Annotate(game string, date time.Time, note, author string) error {
+	if !db.Exists(game, date) {
+		return fmt.Errorf("no %s draw on %s", game, date.Format("2006-01-02"))
+	}
+
+	q := query.NewQuery().Insert("annotations",
+		[]string{"game", "date", "note", "author", "created_at"},
+		game, date, note, author, time.Now().UTC())
+
+	_, err := db.Exec(q.SQL.String(), q.Args...)
+	return err
+}
+
+// History returns the audit trail and annotations of the draw of game on date,
+// oldest first
+func (db *AppDB) History(game string, date time.Time) ([]Change, []Note, error) {
+	q := query.NewQuery().
+		Select("audit", "field", "old_value", "new_value", "author", "changed_at").
+		Where("game = ? AND date = ?", game, date.Format(fmtSqlite)).
+		Order("changed_at", "id")
+
+	rows, err := db.Query(q.SQL.String(), q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		if err := rows.Scan(&c.Field, &c.Old, &c.New, &c.Author, &c.At); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	q = query.NewQuery().
+		Select("annotations", "note", "author", "created_at").
+		Where("game = ? AND date = ?", game, date.Format(fmtSqlite)).
+		Order("created_at", "id")
+
+	rows, err = db.Query(q.SQL.String(), q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		var n Note
+		if err := rows.Scan(&n.Note, &n.Author, &n.At); err != nil {
+			return nil, nil, err
+		}
+		notes = append(notes, n)
+	}
+
+	return changes, notes, rows.Err()
+}
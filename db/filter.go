@@ -0,0 +1,392 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	query "github.com/nboughton/go-sqgenlite"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Sort fields understood by Filter
+const (
+	SortDate    = "date"
+	SortSum     = "sum"
+	SortBonus   = "bonus"
+	SortSet     = "set"
+	SortMachine = "machine"
+	SortDraw    = "draw"
+)
+
+var (
+	sqlBallSum = "(ball1 + ball2 + ball3 + ball4 + ball5 + ball6)"
+	sqlBalls   = "(ball1, ball2, ball3, ball4, ball5, ball6)"
+	sortFields = map[string]string{
+		SortDate:    "date",
+		SortSum:     sqlBallSum,
+		SortBonus:   "bonus",
+		SortSet:     "bset",
+		SortMachine: "bmac",
+		SortDraw:    "draw",
+	}
+)
+
+// Filter describes the draws a query should match. The zero value of each field
+// places no constraint on the results. Filters are built up by chaining the With
+// methods, each of which returns a modified copy.
+type Filter struct {
+	Game     string
+	Begin    time.Time
+	End      time.Time
+	Machines []string
+	Sets     []int
+	Days     []time.Weekday
+	Draws    []int    // Official draw numbers
+	Events   []string // Draws must be part of at least one of these special events
+	HasAll   []int    // Draws must contain every one of these main balls
+	HasAny   []int    // Draws must contain at least one of these main balls
+	Bonus    int
+	SumMin   int // Minimum sum of the main balls
+	SumMax   int // Maximum sum of the main balls
+	Limit    int
+	Offset   int
+	SortBy   string
+	Desc     bool
+}
+
+// NewFilter returns a Filter for lotto draws between begin and end sorted by date
+func NewFilter(begin, end time.Time) Filter {
+	return Filter{Game: lotto.GAME, Begin: begin, End: end, SortBy: SortDate}
+}
+
+// WithMachines constrains the filter to draws using any of machines
+func (f Filter) WithMachines(machines ...string) Filter {
+	f.Machines = append(append([]string{}, f.Machines...), machines...)
+	return f
+}
+
+// WithSets constrains the filter to draws using any of sets
+func (f Filter) WithSets(sets ...int) Filter {
+	f.Sets = append(append([]int{}, f.Sets...), sets...)
+	return f
+}
+
+// WithDays constrains the filter to draws made on any of days
+func (f Filter) WithDays(days ...time.Weekday) Filter {
+	f.Days = append(append([]time.Weekday{}, f.Days...), days...)
+	return f
+}
+
+// WithDraws constrains the filter to draws with any of the official draw numbers
+func (f Filter) WithDraws(draws ...int) Filter {
+	f.Draws = append(append([]int{}, f.Draws...), draws...)
+	return f
+}
+
+// WithEvents constrains the filter to draws that were part of any of events
+func (f Filter) WithEvents(events ...string) Filter {
+	f.Events = append(append([]string{}, f.Events...), events...)
+	return f
+}
+
+// WithAll constrains the filter to draws containing every one of balls
+func (f Filter) WithAll(balls ...int) Filter {
+	f.HasAll = append(append([]int{}, f.HasAll...), balls...)
+	return f
+}
+
+// WithAny constrains the filter to draws containing at least one of balls
+func (f Filter) WithAny(balls ...int) Filter {
+	f.HasAny = append(append([]int{}, f.HasAny...), balls...)
+	return f
+}
+
+// WithBonus constrains the filter to draws with bonus ball n
+func (f Filter) WithBonus(n int) Filter {
+	f.Bonus = n
+	return f
+}
+
+// WithSum constrains the filter to draws whose main balls sum to between min and
+// max. A bound of 0 is ignored.
+func (f Filter) WithSum(min, max int) Filter {
+	f.SumMin, f.SumMax = min, max
+	return f
+}
+
+// WithPage limits the filter to limit draws after skipping offset draws. A limit
+// of 0 returns every draw.
+func (f Filter) WithPage(limit, offset int) Filter {
+	f.Limit, f.Offset = limit, offset
+	return f
+}
+
+// WithSort orders draws by field, which must be one of the Sort constants
+func (f Filter) WithSort(field string, desc bool) Filter {
+	f.SortBy, f.Desc = field, desc
+	return f
+}
+
+// Validate returns an error if the filter can't be turned into a query
+func (f Filter) Validate() error {
+	if _, ok := sortFields[f.SortBy]; f.SortBy != "" && !ok {
+		return fmt.Errorf("unknown sort field %q", f.SortBy)
+	}
+	if f.Limit < 0 || f.Offset < 0 {
+		return fmt.Errorf("limit and offset must not be negative")
+	}
+	for _, b := range append(append([]int{f.Bonus}, f.HasAll...), f.HasAny...) {
+		if b < 0 || b > lotto.MAXBALLVAL {
+			return fmt.Errorf("ball %d is out of range", b)
+		}
+	}
+	if f.SumMax > 0 && f.SumMin > f.SumMax {
+		return fmt.Errorf("minimum sum %d is greater than maximum %d", f.SumMin, f.SumMax)
+	}
+
+	return nil
+}
+
+// where appends the predicates of the filter to q as a WHERE clause
+func (f Filter) where(q *query.Query, d *dialect) *query.Query {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	if f.Game != "" {
+		conds, args = append(conds, "game = ?"), append(args, f.Game)
+	}
+
+	if !f.Begin.IsZero() {
+		conds, args = append(conds, "date >= ?"), append(args, f.Begin.Format(fmtSqlite))
+	}
+
+	if !f.End.IsZero() {
+		conds, args = append(conds, "date <= ?"), append(args, f.End.Format(fmtSqlite))
+	}
+
+	if len(f.Machines) > 0 {
+		conds = append(conds, groupOR("bmac", len(f.Machines)))
+		for _, m := range f.Machines {
+			args = append(args, m)
+		}
+	}
+
+	if len(f.Sets) > 0 {
+		conds = append(conds, groupOR("bset", len(f.Sets)))
+		for _, s := range f.Sets {
+			args = append(args, s)
+		}
+	}
+
+	if len(f.Days) > 0 {
+		conds = append(conds, groupOR(fmt.Sprintf(d.weekday, "date"), len(f.Days)))
+		for _, day := range f.Days {
+			args = append(args, int(day))
+		}
+	}
+
+	if len(f.Draws) > 0 {
+		conds = append(conds, groupOR("draw", len(f.Draws)))
+		for _, n := range f.Draws {
+			args = append(args, n)
+		}
+	}
+
+	if len(f.Events) > 0 {
+		// Event names are matched exactly, as Match does, rather than with LIKE,
+		// which would treat % and _ in them as wildcards and ignore case in SQLite
+		var any []string
+		for _, e := range f.Events {
+			any, args = append(any, fmt.Sprintf(d.contains, "(',' || events || ',')", "?")), append(args, ","+e+",")
+		}
+		conds = append(conds, "("+strings.Join(any, " OR ")+")")
+	}
+
+	for _, b := range f.HasAll {
+		conds, args = append(conds, "? IN "+sqlBalls), append(args, b)
+	}
+
+	if len(f.HasAny) > 0 {
+		var any []string
+		for _, b := range f.HasAny {
+			any, args = append(any, "? IN "+sqlBalls), append(args, b)
+		}
+		conds = append(conds, "("+strings.Join(any, " OR ")+")")
+	}
+
+	if f.Bonus > 0 {
+		conds, args = append(conds, "bonus = ?"), append(args, f.Bonus)
+	}
+
+	if f.SumMin > 0 {
+		conds, args = append(conds, sqlBallSum+" >= ?"), append(args, f.SumMin)
+	}
+
+	if f.SumMax > 0 {
+		conds, args = append(conds, sqlBallSum+" <= ?"), append(args, f.SumMax)
+	}
+
+	if len(conds) > 0 {
+		q.Where(strings.Join(conds, " AND "), args...)
+	}
+
+	return q
+}
+
+// order appends the ORDER BY, LIMIT and OFFSET clauses of the filter to q
+func (f Filter) order(q *query.Query) *query.Query {
+	field, ok := sortFields[f.SortBy]
+	if !ok {
+		field = sortFields[SortDate]
+	}
+
+	dir := ""
+	if f.Desc {
+		dir = " DESC"
+	}
+	q.Order(field+dir, "date"+dir, "id"+dir)
+
+	if f.Limit > 0 || f.Offset > 0 {
+		limit := f.Limit
+		if limit == 0 {
+			limit = math.MaxInt32
+		}
+		q.Append("LIMIT ? OFFSET ?", limit, f.Offset)
+	}
+
+	return q
+}
+
+// ids returns a subquery selecting the ids of every draw matched by the filter
+func (f Filter) ids(d *dialect) *query.Query {
+	return f.order(f.where(query.NewQuery().Select("results", "id"), d))
+}
+
+// Match returns true if res satisfies the predicates of the filter. Sorting and
+// paging are not considered.
+func (f Filter) Match(res lotto.Result) bool {
+	if f.Game != "" && res.Game != f.Game {
+		return false
+	}
+	if (!f.Begin.IsZero() && res.Date.Before(f.Begin)) || (!f.End.IsZero() && res.Date.After(f.End)) {
+		return false
+	}
+	if len(f.Machines) > 0 && !containsString(f.Machines, res.Machine) {
+		return false
+	}
+	if len(f.Sets) > 0 && !containsInt(f.Sets, res.Set) {
+		return false
+	}
+	if len(f.Days) > 0 && !containsDay(f.Days, res.Date.Weekday()) {
+		return false
+	}
+	if len(f.Draws) > 0 && !containsInt(f.Draws, res.Draw) {
+		return false
+	}
+	if len(f.Events) > 0 {
+		found := false
+		for _, e := range f.Events {
+			if res.HasEvent(e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, b := range f.HasAll {
+		if !containsInt(res.Balls, b) {
+			return false
+		}
+	}
+	if len(f.HasAny) > 0 {
+		found := false
+		for _, b := range f.HasAny {
+			if containsInt(res.Balls, b) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Bonus > 0 && res.Bonus != f.Bonus {
+		return false
+	}
+
+	sum := ballSum(res)
+	if (f.SumMin > 0 && sum < f.SumMin) || (f.SumMax > 0 && sum > f.SumMax) {
+		return false
+	}
+
+	return true
+}
+
+// Apply sorts and pages a set of results that have already been matched against
+// the filter
+func (f Filter) Apply(set lotto.ResultSet) lotto.ResultSet {
+	out := append(lotto.ResultSet{}, set...)
+
+	key := func(r lotto.Result) string {
+		switch f.SortBy {
+		case SortSum:
+			return fmt.Sprintf("%03d", ballSum(r))
+		case SortBonus:
+			return fmt.Sprintf("%03d", r.Bonus)
+		case SortSet:
+			return fmt.Sprintf("%03d", r.Set)
+		case SortMachine:
+			return r.Machine
+		case SortDraw:
+			return fmt.Sprintf("%08d", r.Draw)
+		}
+		return ""
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if f.Desc {
+			a, b = b, a
+		}
+
+		if ka, kb := key(a), key(b); ka != kb {
+			return ka < kb
+		}
+		return a.Date.Before(b.Date)
+	})
+
+	if f.Offset >= len(out) {
+		return nil
+	}
+	out = out[f.Offset:]
+
+	if f.Limit > 0 && f.Limit < len(out) {
+		out = out[:f.Limit]
+	}
+
+	return out
+}
+
+func ballSum(res lotto.Result) int {
+	sum := 0
+	for _, b := range res.Balls {
+		sum += b
+	}
+	return sum
+}
+
+func containsDay(slc []time.Weekday, d time.Weekday) bool {
+	for _, v := range slc {
+		if v == d {
+			return true
+		}
+	}
+	return false
+}
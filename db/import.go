@@ -0,0 +1,96 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// File formats understood by ReadFile
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatOperator = "operator" // The lottery operator's draw history download
+)
+
+// ReadFile reads a set of results, as written by the export command, from path.
+// Results without a provenance, which includes every result read from a CSV file,
+// are given the file they were read from as theirs. Any other result keeps the
+// provenance it was exported with.
+func ReadFile(path, format string) (lotto.ResultSet, error) {
+	var set lotto.ResultSet
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(b, &set)
+	case FormatCSV:
+		set, err = lotto.ReadCSV(bytes.NewReader(b))
+	case FormatOperator:
+		set, err = ReadOperatorCSV(bytes.NewReader(b))
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src := lotto.Provenance{
+		Source:        "file://" + filepath.ToSlash(abs),
+		FetchedAt:     time.Now().UTC(),
+		Hash:          hashBytes(b),
+		ParserVersion: format + "/1",
+	}
+	for i := range set {
+		if set[i].Provenance.Source == "" {
+			set[i].Provenance = src
+		}
+	}
+
+	return set, nil
+}
+
+// Import upserts each result in set into s. Results that fail validation are
+// rejected and results for draws that have been corrected by hand are skipped. It
+// returns the number of results stored, the number skipped and an error for each
+// result rejected.
+func Import(s Store, set lotto.ResultSet) (int, int, []error) {
+	var (
+		n, skipped = 0, 0
+		errs       []error
+	)
+
+	for i, res := range set {
+		if res.Game == "" {
+			res.Game = lotto.GAME
+		}
+		res.Date = res.Date.UTC()
+
+		err := s.Upsert(res)
+		if err == ErrManual {
+			skipped++
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %s", i+1, err))
+			continue
+		}
+		n++
+	}
+
+	return n, skipped, errs
+}
@@ -0,0 +1,356 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nboughton/go-utils/json/file"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// MemDB is a Store that holds results in memory. It is useful for tests and for
+// embedding stalotto where a SQLite file isn't wanted.
+type MemDB struct {
+	mu      sync.RWMutex
+	results lotto.ResultSet // Kept in date order
+}
+
+// NewMemDB returns an empty in-memory Store
+func NewMemDB() *MemDB {
+	return &MemDB{}
+}
+
+// LoadJSON reads a JSON export, as written by the export command, into the store.
+// Invalid records are logged and skipped so that exports holding them can still be
+// loaded and checked.
+func (m *MemDB) LoadJSON(path string) error {
+	var set lotto.ResultSet
+	if err := file.Scan(path, &set); err != nil {
+		return err
+	}
+
+	for _, res := range set {
+		err := m.Upsert(res)
+		if isInvalid(err) {
+			log.Println(err)
+			continue
+		}
+		if err != nil && err != ErrManual {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Missing returns the scheduled draw dates of game between begin and end that have
+// no record in the store
+func (m *MemDB) Missing(game lotto.Game, begin, end time.Time) ([]time.Time, error) {
+	var missing []time.Time
+	for _, d := range game.DrawDates(begin, end) {
+		if !m.Exists(game.Name, d) {
+			missing = append(missing, d)
+		}
+	}
+
+	return missing, nil
+}
+
+// Upsert inserts a result or, if a result with the same game, date and draw number
+// already exists, replaces it. Draws that have been corrected by hand are never
+// replaced, even if the correction changed the draw number, and ErrManual is
+// returned for them instead.
+func (m *MemDB) Upsert(res lotto.Result) error {
+	if res.Game == "" {
+		res.Game = lotto.GAME
+	}
+	res.Date = res.Date.UTC()
+
+	if err := validate(res); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.results {
+		if r.Game == res.Game && r.Date.Equal(res.Date) && r.Provenance.Source == lotto.SourceManual {
+			return ErrManual
+		}
+	}
+
+	// Draw numbers aren't known by every source so a result with a draw number
+	// replaces a stored result without one and vice versa
+	for i, r := range m.results {
+		if r.Game == res.Game && r.Date.Equal(res.Date) && (r.Draw == res.Draw || r.Draw == 0 || res.Draw == 0) {
+			if res.Draw == 0 {
+				res.Draw = r.Draw
+			}
+			keepDrawInfo(&res, r)
+			m.results[i] = res
+			return nil
+		}
+	}
+
+	i := sort.Search(len(m.results), func(i int) bool { return m.results[i].Date.After(res.Date) })
+	m.results = append(m.results, lotto.Result{})
+	copy(m.results[i+1:], m.results[i:])
+	m.results[i] = res
+
+	return nil
+}
+
+// Exists returns true if a record for game with t timestamp exists
+func (m *MemDB) Exists(game string, t time.Time) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.results {
+		if r.Game == game && r.Date.Equal(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Result retrieves a single record
+func (m *MemDB) Result(t time.Time) (lotto.Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.results {
+		if r.Date.Equal(t) {
+			return r, nil
+		}
+	}
+
+	return lotto.Result{}, sql.ErrNoRows
+}
+
+// filter returns the results matched by f, sorted and paged as f requires
+func (m *MemDB) filter(f Filter) lotto.ResultSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out lotto.ResultSet
+	for _, r := range m.results {
+		if f.Match(r) {
+			out = append(out, r)
+		}
+	}
+
+	return f.Apply(out)
+}
+
+// Results streams records matched by f over the first channel returned. The second
+// channel receives at most one error and is closed once the results channel has
+// been closed. Cancelling ctx stops the stream.
+func (m *MemDB) Results(ctx context.Context, f Filter) (<-chan lotto.Result, <-chan error) {
+	c, errc := make(chan lotto.Result), make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(c)
+
+		if err := f.Validate(); err != nil {
+			errc <- err
+			return
+		}
+
+		for _, res := range m.filter(f) {
+			select {
+			case c <- res:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return c, errc
+}
+
+// Machines returns the distinct machine names in the draws matched by f
+func (m *MemDB) Machines(f Filter) ([]string, error) {
+	var out []string
+	for _, r := range m.filter(f) {
+		if !containsString(out, r.Machine) {
+			out = append(out, r.Machine)
+		}
+	}
+	sort.Strings(out)
+
+	return out, nil
+}
+
+// Sets returns the distinct sets in the draws matched by f
+func (m *MemDB) Sets(f Filter) ([]int, error) {
+	var out []int
+	for _, r := range m.filter(f) {
+		if !containsInt(out, r.Set) {
+			out = append(out, r.Set)
+		}
+	}
+	sort.Ints(out)
+
+	return out, nil
+}
+
+// LastDraw retrieves the most recent set of results
+func (m *MemDB) LastDraw() (lotto.Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.results) == 0 {
+		return lotto.Result{}, sql.ErrNoRows
+	}
+
+	return m.results[len(m.results)-1], nil
+}
+
+// DataRange retrieves the first and last record dates
+func (m *MemDB) DataRange() (time.Time, time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.results) == 0 {
+		return time.Now(), time.Now(), sql.ErrNoRows
+	}
+
+	return m.results[0].Date, m.results[len(m.results)-1].Date, nil
+}
+
+// MachineSetFreq returns the combinations of machine/set in the draws matched by f and how many times each has been drawn
+func (m *MemDB) MachineSetFreq(f Filter) ([]MacSetFreq, error) {
+	var out []MacSetFreq
+	for _, r := range m.filter(f) {
+		found := false
+		for i := range out {
+			if out[i].Machine == r.Machine && out[i].Set == r.Set {
+				out[i].Freq++
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			out = append(out, MacSetFreq{Machine: r.Machine, Set: r.Set, Freq: 1})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Freq < out[j].Freq })
+
+	return out, nil
+}
+
+// Frequencies returns the frequency sets for balls and bonus balls in the draws
+// matched by f
+func (m *MemDB) Frequencies(f Filter) (balls lotto.FrequencySet, bonus lotto.FrequencySet, err error) {
+	balls, bonus = lotto.NewFrequencySet(lotto.MAXBALLVAL), lotto.NewFrequencySet(lotto.MAXBALLVAL)
+	for _, r := range m.filter(f) {
+		for _, b := range r.Balls {
+			if b > 0 && b <= lotto.MAXBALLVAL {
+				balls[b-1].Frequency++
+			}
+		}
+		if r.Bonus > 0 && r.Bonus <= lotto.MAXBALLVAL {
+			bonus[r.Bonus-1].Frequency++
+		}
+	}
+
+	return balls, bonus, nil
+}
+
+// Gaps returns the draw gap stats for each main ball in the draws matched by f
+func (m *MemDB) Gaps(f Filter) ([]BallGap, error) {
+	var (
+		set  = f.WithSort(SortDate, false).WithPage(0, 0).Apply(m.filter(f))
+		last = make(map[int]int)
+		gaps = make(map[int]*BallGap)
+	)
+
+	for n, r := range set {
+		for _, b := range r.Balls {
+			g, ok := gaps[b]
+			if !ok {
+				g = &BallGap{Ball: b}
+				gaps[b] = g
+			}
+
+			// Draws are numbered from 1 so a ball with no previous draw counts from 0
+			if gap := n - last[b]; gap > g.Longest {
+				g.Longest = gap
+			}
+			last[b] = n + 1
+		}
+	}
+
+	var out []BallGap
+	for b, g := range gaps {
+		g.Current = len(set) - last[b]
+		if g.Current > g.Longest {
+			g.Longest = g.Current
+		}
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Ball < out[j].Ball })
+
+	return out, nil
+}
+
+// CoOccurrence returns the limit most frequently drawn pairs of main balls in the
+// draws matched by f
+func (m *MemDB) CoOccurrence(f Filter, limit int) ([]BallPair, error) {
+	counts := make(map[[2]int]int)
+	for _, r := range m.filter(f) {
+		for _, a := range r.Balls {
+			for _, b := range r.Balls {
+				if a < b {
+					counts[[2]int{a, b}]++
+				}
+			}
+		}
+	}
+
+	var out []BallPair
+	for k, n := range counts {
+		out = append(out, BallPair{A: k[0], B: k[1], Freq: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Freq != out[j].Freq {
+			return out[i].Freq > out[j].Freq
+		}
+		if out[i].A != out[j].A {
+			return out[i].A < out[j].A
+		}
+		return out[i].B < out[j].B
+	})
+
+	if limit >= 0 && len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out, nil
+}
+
+func containsString(slc []string, s string) bool {
+	for _, v := range slc {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(slc []int, n int) bool {
+	for _, v := range slc {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	query "github.com/nboughton/go-sqgenlite"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Merge policies understood by MergePolicy
+const (
+	MergeNewest      = "newest"      // The most recently fetched result wins
+	MergeManual      = "manual"      // Manual corrections win, otherwise the newest result
+	MergeInteractive = "interactive" // Each conflict is put to the user
+)
+
+// Conflict describes a draw stored with different values in two databases
+type Conflict struct {
+	Local   lotto.Result
+	Other   lotto.Result
+	Changes []Change // Differences from Local to Other
+	Theirs  bool     // Set once resolved if Other was kept
+}
+
+// Resolver decides a conflict, returning true if the other database's result should
+// replace the local one
+type Resolver func(c Conflict) (bool, error)
+
+// MergeReport summarises a merge
+type MergeReport struct {
+	Added       int
+	Filled      int // Draws given values they were missing
+	Unchanged   int
+	Annotations int
+	Conflicts   []Conflict
+	Rejected    []error // Draws from the other database, or merged with it, that failed validation
+}
+
+// MergePolicy returns the Resolver for a non-interactive policy
+func MergePolicy(name string) (Resolver, error) {
+	switch name {
+	case MergeNewest:
+		return newestWins, nil
+	case MergeManual:
+		return manualWins, nil
+	}
+
+	return nil, fmt.Errorf("unknown merge policy %q", name)
+}
+
+func newestWins(c Conflict) (bool, error) {
+	return c.Other.Provenance.FetchedAt.After(c.Local.Provenance.FetchedAt), nil
+}
+
+func manualWins(c Conflict) (bool, error) {
+	local, other := c.Local.Provenance.Source == lotto.SourceManual, c.Other.Provenance.Source == lotto.SourceManual
+	if local != other {
+		return other, nil
+	}
+
+	return newestWins(c)
+}
+
+// Merge reconciles the draws and annotations held in other with the database. Draws
+// are matched by game, date and draw number. Draws missing locally are added and
+// values missing from either copy of a draw are filled in. Where both copies hold
+// different values resolve decides which is kept. Every change is added to the
+// audit trail of the draw under author.
+func (db *AppDB) Merge(other *AppDB, resolve Resolver, author string) (MergeReport, error) {
+	var report MergeReport
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results, errc := other.Results(ctx, Filter{SortBy: SortDate})
+	for res := range results {
+		id, local, err := db.find(res)
+		if err == sql.ErrNoRows {
+			// A draw corrected by hand here may have been given another draw number
+			err := db.Upsert(res)
+			if err == ErrManual {
+				report.Unchanged++
+				continue
+			}
+			if err != nil {
+				report.Rejected = append(report.Rejected, fmt.Errorf("%s: %s", res, err))
+				continue
+			}
+			report.Added++
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		merged, fill := local, sameDraw(local, res)
+		if fill {
+			merged.Balls = append([]int{}, local.Balls...)
+			mergeResult(&merged, res)
+			if merged.Draw == 0 {
+				merged.Draw = res.Draw
+			}
+		} else {
+			c := Conflict{Local: local, Other: res, Changes: diffResults(local, res)}
+			if c.Theirs, err = resolve(c); err != nil {
+				return report, err
+			}
+			report.Conflicts = append(report.Conflicts, c)
+
+			if !c.Theirs {
+				continue
+			}
+			merged = res
+		}
+
+		changes := diffResults(local, merged)
+		if len(changes) == 0 {
+			report.Unchanged++
+			continue
+		}
+		// The other copy, or a mix of both, may break the rules of the game
+		if err := validate(merged); err != nil {
+			if !fill {
+				report.Conflicts[len(report.Conflicts)-1].Theirs = false
+			}
+			report.Rejected = append(report.Rejected, err)
+			continue
+		}
+		if merged.Provenance.Source != local.Provenance.Source {
+			changes = append(changes, Change{Field: "source", Old: local.Provenance.Source, New: merged.Provenance.Source})
+		}
+
+		if err := db.replace(id, local, merged, changes, author); err != nil {
+			return report, fmt.Errorf("%s: %s", res, err)
+		}
+		if fill {
+			report.Filled++
+		}
+	}
+	if err := <-errc; err != nil {
+		return report, err
+	}
+
+	n, err := db.mergeAnnotations(other)
+	report.Annotations = n
+
+	return report, err
+}
+
+// find returns the id and values of the stored result with the same identity as res
+func (db *AppDB) find(res lotto.Result) (int64, lotto.Result, error) {
+	q := query.NewQuery().
+		Select("results", append([]string{"id"}, allFields...)...).
+		Where("game = ? AND date = ? AND (draw = ? OR draw = 0 OR ? = 0)", res.Game, res.Date.Format(fmtSqlite), res.Draw, res.Draw).
+		Order("id").
+		Append("LIMIT 1")
+
+	var id int64
+	local, err := scanResult(db.QueryRow(q.SQL.String(), q.Args...), &id)
+
+	return id, local, err
+}
+
+// replace overwrites the stored result old, stored with id, with res
+func (db *AppDB) replace(id int64, old, res lotto.Result, changes []Change, author string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := db.overwrite(tx, id, res, changes, author); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return db.invalidate(old, res)
+}
+
+// mergeAnnotations copies any annotations in other that aren't held by the database
+// and returns the number copied
+func (db *AppDB) mergeAnnotations(other *AppDB) (int, error) {
+	q := query.NewQuery().Select("annotations", "game", "date", "note", "author", "created_at").Order("id")
+
+	rows, err := other.Query(q.SQL.String())
+	if err != nil {
+		return 0, err
+	}
+
+	type annotation struct {
+		game, note, author string
+		date, at           time.Time
+	}
+	var notes []annotation
+	for rows.Next() {
+		var a annotation
+		if err := rows.Scan(&a.game, &a.date, &a.note, &a.author, &a.at); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		notes = append(notes, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, a := range notes {
+		e := query.NewQuery().
+			Select("annotations", "COUNT(id)").
+			Where("game = ? AND date = ? AND note = ? AND author = ?", a.game, a.date.Format(fmtSqlite), a.note, a.author)
+
+		count := 0
+		if err := db.QueryRow(e.SQL.String(), e.Args...).Scan(&count); err != nil {
+			return n, err
+		}
+		if count > 0 {
+			continue
+		}
+
+		i := query.NewQuery().Insert("annotations",
+			[]string{"game", "date", "note", "author", "created_at"},
+			a.game, a.date, a.note, a.author, a.at)
+		if _, err := db.Exec(i.SQL.String(), i.Args...); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nboughton/stalotto/lotto"
+)
+
+var (
+	// Date formats used by the operator's draw history downloads
+	fmtOperatorDates = []string{"02-Jan-2006", "02/01/2006", "2006-01-02"}
+
+	operatorBalls = []string{"ball 1", "ball 2", "ball 3", "ball 4", "ball 5", "ball 6"}
+)
+
+// ReadOperatorCSV reads the draw history CSV published by the lottery operator.
+// Columns are matched by name so that extra columns, such as Raffles, are ignored.
+func ReadOperatorCSV(r io.Reader) (lotto.ResultSet, error) {
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = -1
+	c.TrimLeadingSpace = true
+
+	header, err := c.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	cols := make(map[string]int)
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	for _, name := range append([]string{"drawdate", "bonus ball", "ball set", "machine", "drawnumber"}, operatorBalls...) {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var (
+		set  lotto.ResultSet
+		line = 1
+	)
+	for {
+		row, err := c.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := parseOperatorRow(row, cols)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		set = append(set, res)
+	}
+
+	return set, nil
+}
+
+func parseOperatorRow(row []string, cols map[string]int) (lotto.Result, error) {
+	res := lotto.NewResult()
+
+	field := func(name string) string {
+		if i := cols[name]; i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	date, err := parseOperatorDate(field("drawdate"))
+	if err != nil {
+		return res, err
+	}
+	res.Date = date
+
+	ints := map[string]*int{"bonus ball": &res.Bonus, "ball set": &res.Set, "drawnumber": &res.Draw}
+	for i, name := range operatorBalls {
+		ints[name] = &res.Balls[i]
+	}
+
+	for name, v := range ints {
+		if *v, err = strconv.Atoi(field(name)); err != nil {
+			return res, fmt.Errorf("%s: %s", name, err)
+		}
+	}
+
+	res.Machine = operatorMachine(field("machine"))
+	return res, nil
+}
+
+func parseOperatorDate(s string) (time.Time, error) {
+	for _, f := range fmtOperatorDates {
+		if t, err := time.Parse(f, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("bad date: %s", s)
+}
+
+// operatorMachine converts the operator's upper case machine names, e.g. MERLIN,
+// to the capitalised form used by the scraper, e.g. Merlin
+func operatorMachine(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
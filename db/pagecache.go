@@ -0,0 +1,112 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pageCache is a directory of fetched pages. Each body is stored once under its
+// SHA-256 hash in objects/ and index/ maps the path a page was fetched from to the
+// hash of its latest body, so older versions of a page are kept.
+type pageCache string
+
+// errNotCached is returned by get for a page that has never been fetched
+type errNotCached string
+
+func (e errNotCached) Error() string {
+	return fmt.Sprintf("%s: not in the page cache", string(e))
+}
+
+func (pc pageCache) object(hash string) string {
+	return filepath.Join(string(pc), "objects", hash[:2], hash)
+}
+
+func (pc pageCache) index(path string) string {
+	return filepath.Join(string(pc), "index", hashBytes([]byte(path)))
+}
+
+// put stores body as the page at path, fetched at time at
+func (pc pageCache) put(path string, body []byte, at time.Time) error {
+	hash := hashBytes(body)
+
+	obj := pc.object(hash)
+	if _, err := os.Stat(obj); os.IsNotExist(err) {
+		if err := writeAtomic(obj, body); err != nil {
+			return err
+		}
+	}
+
+	return writeAtomic(pc.index(path), []byte(fmt.Sprintf("%s %s\n%s\n", hash, at.UTC().Format(time.RFC3339), path)))
+}
+
+// get returns the latest body stored for path, its hash and when it was fetched
+func (pc pageCache) get(path string) ([]byte, string, time.Time, error) {
+	idx, err := ioutil.ReadFile(pc.index(path))
+	if os.IsNotExist(err) {
+		return nil, "", time.Time{}, errNotCached(path)
+	}
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	var (
+		hash string
+		at   time.Time
+	)
+	fields := strings.Fields(string(idx))
+	if len(fields) < 2 {
+		return nil, "", at, fmt.Errorf("%s: bad page cache index entry", path)
+	}
+	// The hash names the object's file, so anything but a SHA-256 in hex would
+	// read some other file or none at all
+	hash = fields[0]
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+		return nil, "", at, fmt.Errorf("%s: bad page cache index entry: hash %q", path, hash)
+	}
+	if at, err = time.Parse(time.RFC3339, fields[1]); err != nil {
+		return nil, "", at, fmt.Errorf("%s: bad page cache index entry: %s", path, err)
+	}
+
+	body, err := ioutil.ReadFile(pc.object(hash))
+	if err != nil {
+		return nil, "", at, err
+	}
+
+	// Catch objects damaged on disk rather than parse them
+	if hashBytes(body) != hash {
+		return nil, "", at, fmt.Errorf("%s: cached page doesn't match its hash", path)
+	}
+
+	return body, hash, at, nil
+}
+
+// writeAtomic writes data to a file alongside path and renames it into place so
+// that readers never see a partial file
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	_ "github.com/lib/pq" // Import for postgres db driver
+)
+
+var (
+	pgSchema = `CREATE TABLE IF NOT EXISTS results (id SERIAL PRIMARY KEY, date TIMESTAMPTZ, bset INT, bmac TEXT, ball1 INT, ball2 INT, ball3 INT, ball4 INT, ball5 INT, ball6 INT, bonus INT);
+		CREATE TABLE IF NOT EXISTS schema_version (version INT NOT NULL)`
+
+	// pgMigrations mirror sqlMigrations so that both databases end up with
+	// equivalent schemas
+	pgMigrations = []string{
+		"ALTER TABLE results ADD COLUMN game TEXT NOT NULL DEFAULT 'lotto'; ALTER TABLE results ADD COLUMN draw INT NOT NULL DEFAULT 0",
+		pgDrawBalls,
+		pgProvenance,
+		pgAudit,
+		pgStatsCache,
+		pgDrawInfo,
+		pgStatsCacheDates,
+	}
+
+	pgStatsCacheDates = `ALTER TABLE stats_cache ADD COLUMN begin_date TIMESTAMPTZ;
+		ALTER TABLE stats_cache ADD COLUMN end_date TIMESTAMPTZ;
+		CREATE INDEX stats_cache_created ON stats_cache (created_at)`
+
+	pgDrawInfo = `ALTER TABLE results ADD COLUMN drawn_at TIMESTAMPTZ;
+		ALTER TABLE results ADD COLUMN events TEXT NOT NULL DEFAULT '';
+		CREATE INDEX results_draw_number ON results (game, draw)`
+
+	pgStatsCache = `CREATE TABLE stats_cache (game TEXT NOT NULL, kind TEXT NOT NULL, signature TEXT NOT NULL, filter TEXT NOT NULL, data TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL, PRIMARY KEY (game, kind, signature))`
+
+	pgAudit = `CREATE TABLE annotations (id SERIAL PRIMARY KEY, game TEXT NOT NULL, date TIMESTAMPTZ NOT NULL, note TEXT NOT NULL, author TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL);
+		CREATE INDEX annotations_draw ON annotations (game, date);
+		CREATE TABLE audit (id SERIAL PRIMARY KEY, game TEXT NOT NULL, date TIMESTAMPTZ NOT NULL, field TEXT NOT NULL, old_value TEXT NOT NULL, new_value TEXT NOT NULL, author TEXT NOT NULL, changed_at TIMESTAMPTZ NOT NULL);
+		CREATE INDEX audit_draw ON audit (game, date)`
+
+	pgProvenance = `ALTER TABLE results ADD COLUMN source TEXT NOT NULL DEFAULT '';
+		ALTER TABLE results ADD COLUMN fetched_at TIMESTAMPTZ;
+		ALTER TABLE results ADD COLUMN source_hash TEXT NOT NULL DEFAULT '';
+		ALTER TABLE results ADD COLUMN parser_version TEXT NOT NULL DEFAULT ''`
+
+	pgDrawBalls = `CREATE TABLE draw_balls (draw_id INTEGER NOT NULL REFERENCES results (id) ON DELETE CASCADE, position INT NOT NULL, ball INT NOT NULL, is_bonus INT NOT NULL DEFAULT 0, PRIMARY KEY (draw_id, position));
+		CREATE INDEX draw_balls_ball ON draw_balls (ball, is_bonus);
+		INSERT INTO draw_balls (draw_id, position, ball, is_bonus)
+			SELECT id, 1, ball1, 0 FROM results UNION ALL SELECT id, 2, ball2, 0 FROM results UNION ALL
+			SELECT id, 3, ball3, 0 FROM results UNION ALL SELECT id, 4, ball4, 0 FROM results UNION ALL
+			SELECT id, 5, ball5, 0 FROM results UNION ALL SELECT id, 6, ball6, 0 FROM results UNION ALL
+			SELECT id, 7, bonus, 1 FROM results;
+		CREATE FUNCTION results_balls() RETURNS trigger AS $$ BEGIN
+			DELETE FROM draw_balls WHERE draw_id = NEW.id;
+			INSERT INTO draw_balls (draw_id, position, ball, is_bonus) VALUES
+				(NEW.id, 1, NEW.ball1, 0), (NEW.id, 2, NEW.ball2, 0), (NEW.id, 3, NEW.ball3, 0),
+				(NEW.id, 4, NEW.ball4, 0), (NEW.id, 5, NEW.ball5, 0), (NEW.id, 6, NEW.ball6, 0),
+				(NEW.id, 7, NEW.bonus, 1);
+			RETURN NULL;
+		END $$ LANGUAGE plpgsql;
+		CREATE TRIGGER results_balls AFTER INSERT OR UPDATE OF ball1, ball2, ball3, ball4, ball5, ball6, bonus ON results
+			FOR EACH ROW EXECUTE PROCEDURE results_balls();`
+)
+
+// postgres is the dialect used for shared PostgreSQL databases
+var postgres = &dialect{
+	driver:       "postgres",
+	schema:       pgSchema,
+	migrations:   pgMigrations,
+	hasResults:   "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'results'",
+	version:      "SELECT COALESCE(MAX(version), 0) FROM schema_version",
+	setVersion:   "INSERT INTO schema_version (version) VALUES (%d)",
+	groupIDs:     "STRING_AGG(id::TEXT, ',' ORDER BY id)",
+	weekday:      "EXTRACT(DOW FROM %s AT TIME ZONE 'UTC')",
+	contains:     "STRPOS(%s, %s) > 0",
+	numberedArgs: true,
+}
+
+// IsPostgres returns true if dsn refers to a PostgreSQL database
+func IsPostgres(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// ConnectPostgres returns a DB connection wrapper for the PostgreSQL database at dsn.
+// Unlike SQLite the connection pool is left enabled so that several readers can
+// share the database at once. Snapshots aren't supported so any snapshot options
+// are ignored.
+func ConnectPostgres(dsn string, opts Options) *AppDB {
+	db := openPostgres(dsn)
+	opts.AutoSnapshot = false
+
+	if opts.ReadOnly {
+		return newReadOnlyAppDB(db, postgres, dsn, opts)
+	}
+	return newAppDB(db, db, postgres, dsn, opts)
+}
+
+func openPostgres(dsn string) *sql.DB {
+	db, err := sql.Open(postgres.driver, dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := db.Ping(); err != nil {
+		log.Fatal(err)
+	}
+
+	return db
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket. Tokens are added at rate per second up to burst
+// and each request takes one, waiting for it if the bucket is empty.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a full bucket. A rate of 0 or less doesn't limit requests.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a token can be taken or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		d := l.take()
+		if d == 0 {
+			return nil
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// take removes a token from the bucket if there is one, otherwise it returns how
+// long until there will be
+func (l *rateLimiter) take() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// slow lowers the rate so that requests are at least delay apart
+func (l *rateLimiter) slow(delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r := 1 / delay.Seconds(); l.rate <= 0 || r < l.rate {
+		l.rate, l.burst = r, 1
+		if l.tokens > 1 {
+			l.tokens = 1
+		}
+	}
+}
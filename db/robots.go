@@ -0,0 +1,111 @@
+package db
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robots holds the rules of a robots.txt that apply to one user agent
+type robots struct {
+	rules []robotsRule
+	delay time.Duration // Crawl-delay, 0 if not given
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// parseRobots reads the rules that apply to agent from a robots.txt. The group
+// naming agent is used if there is one, otherwise the group for every agent.
+func parseRobots(r io.Reader, agent string) (robots, error) {
+	var (
+		named, every robots
+		found        bool
+		group        []*robots // Groups the current record applies to
+		inAgents     bool      // Reading the User-agent lines that start a record
+		sc           = bufio.NewScanner(r)
+	)
+	agent = strings.ToLower(agent)
+
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key, val := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+
+		if key == "user-agent" {
+			if !inAgents {
+				group, inAgents = nil, true
+			}
+
+			switch v := strings.ToLower(val); {
+			case v == "*":
+				group = append(group, &every)
+			case v != "" && strings.Contains(agent, v):
+				group, found = append(group, &named), true
+			}
+			continue
+		}
+		inAgents = false
+
+		for _, g := range group {
+			switch key {
+			case "allow", "disallow":
+				// An empty Disallow allows everything so adds nothing
+				if val != "" {
+					g.rules = append(g.rules, newRobotsRule(key == "allow", val))
+				}
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(val, 64); err == nil && secs > 0 {
+					g.delay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if found {
+		return named, sc.Err()
+	}
+	return every, sc.Err()
+}
+
+// newRobotsRule compiles a path pattern in which * matches any characters and a
+// trailing $ anchors the end of the path
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	if strings.HasSuffix(expr, `\$`) {
+		expr = strings.TrimSuffix(expr, `\$`) + "$"
+	}
+
+	return robotsRule{allow: allow, pattern: pattern, re: regexp.MustCompile("^" + expr)}
+}
+
+// allowed reports whether path may be fetched. The longest matching rule decides,
+// with Allow winning a tie, and paths matching no rule are allowed.
+func (r robots) allowed(path string) bool {
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allow, longest = rule.allow, n
+		}
+	}
+
+	return allow
+}
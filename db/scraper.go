@@ -1,80 +1,454 @@
 package db
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/nboughton/stalotto/lotto"
 )
 
-var (
-	baseURL    = "https://www.lottery.co.uk"
-	archiveURL = "%s/lotto/results/archive-%d"
+var archiveURL = "%s/lotto/results/archive-%d"
+
+// Defaults used by a Scraper when it isn't configured otherwise
+const (
+	DefaultBaseURL   = "https://www.lottery.co.uk"
+	DefaultUserAgent = "stalotto (+https://github.com/nboughton/stalotto)"
+	DefaultTimeout   = 30 * time.Second
+	DefaultRate      = 2 // Requests per second
+	DefaultBurst     = 4
+	DefaultRetries   = 4
+	DefaultBackoff   = time.Second
+	DefaultWorkers   = 4
+	maxBackoff       = time.Minute
+)
+
+// ScraperVersion is recorded against every scraped result and should be bumped
+// whenever a change to the parser could change the results it produces
+const ScraperVersion = "scraper/2"
+
+// ScraperName is the name of the lottery.co.uk scraper Source
+const ScraperName = "lottery.co.uk"
+
+// Scraper is a Source that scrapes results from the lottery.co.uk archive, or from
+// a mirror of it at BaseURL. Requests are rate limited, retried with backoff when
+// they fail for reasons that may pass and never made to paths that robots.txt
+// disallows.
+type Scraper struct {
+	Client    *http.Client
+	BaseURL   string
+	UserAgent string
+	Rate      float64       // Requests per second, unlimited if 0
+	Burst     int           // Requests that can be made back to back before Rate applies
+	Retries   int           // Attempts at a page after the first
+	Backoff   time.Duration // Delay before the first retry, doubled for each after
+	Workers   int           // Result pages of an archive year fetched at once
+	CacheDir  string        // Page cache that fetched pages are added to, none if empty
+	Offline   bool          // Read pages from CacheDir instead of the site
+
+	limiterOnce sync.Once
+	limiter     *rateLimiter
+	robotsOnce  sync.Once
+	robots      robots
+	robotsErr   error
+}
+
+// Selectors that find the parts of a page the scraper reads
+const (
+	selArchiveLinks = "#siteContainer .main .lotto tbody tr td a"
+	selBalls        = ".result"
+	selDetails      = "#siteContainer .main .lotto tbody tr td"
+	selEvents       = "#siteContainer .main .drawEvent"
 )
 
-// Scrape archive for data
-func Scrape() <-chan lotto.Result {
-	c := make(chan lotto.Result)
+// eventPhrases maps phrases in the event banners of a result page, in lower case, to
+// the special events they announce
+var eventPhrases = map[string]string{
+	"must be won": lotto.EventMustBeWon,
+	"raffle":      lotto.EventRaffle,
+}
+
+// SelectorError is returned for a page that doesn't have the structure the scraper
+// expects, which usually means the site's markup has changed. A scrape that meets
+// one stops rather than store results that may have been parsed wrongly.
+type SelectorError struct {
+	URL      string
+	Problems []string
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("%s: %s, the site's markup may have changed", e.URL, strings.Join(e.Problems, "; "))
+}
+
+// ScrapeError lists the pages a scrape gave up on. The scrape carries on past them
+// so every other result is still delivered.
+type ScrapeError struct {
+	Failed []error
+}
+
+func (e *ScrapeError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d pages failed:", len(e.Failed))
+	for _, err := range e.Failed {
+		fmt.Fprintf(&b, "\n  %s", err)
+	}
+	return b.String()
+}
+
+// add logs a page failure and records it
+func (e *ScrapeError) add(err error) {
+	log.Println(err)
+	e.Failed = append(e.Failed, err)
+}
+
+// result returns e if any pages failed
+func (e *ScrapeError) result() error {
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
+
+// statusError is returned for a response other than 200 OK
+type statusError struct {
+	url        string
+	code       int
+	status     string
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.url, e.status)
+}
+
+// NewScraper returns a Scraper that fetches pages from baseURL with client. An
+// empty baseURL or userAgent is replaced by its default and a nil client by one
+// built by NewHTTPClient with the default timeout.
+func NewScraper(client *http.Client, baseURL, userAgent string) *Scraper {
+	if client == nil {
+		client, _ = NewHTTPClient(DefaultTimeout, "")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	return &Scraper{
+		Client:    client,
+		BaseURL:   strings.TrimSuffix(baseURL, "/"),
+		UserAgent: userAgent,
+		Rate:      DefaultRate,
+		Burst:     DefaultBurst,
+		Retries:   DefaultRetries,
+		Backoff:   DefaultBackoff,
+		Workers:   DefaultWorkers,
+	}
+}
+
+// NewHTTPClient returns a client whose requests time out after timeout. Requests
+// are sent through proxy if it's set, otherwise through any proxy set in the
+// environment.
+func NewHTTPClient(timeout time.Duration, proxy string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("bad proxy url: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// Name satisfies the Source interface
+func (s *Scraper) Name() string {
+	return ScraperName
+}
+
+// Results scrapes the archive pages for each year from end back to begin, newest
+// first, and sends the results drawn between begin and end. Pages that can't be
+// fetched or parsed are skipped and listed by a ScrapeError once the scrape ends.
+func (s *Scraper) Results(ctx context.Context, game lotto.Game, begin, end time.Time) (<-chan lotto.Result, <-chan error) {
+	c, errc := make(chan lotto.Result), make(chan error, 1)
 
 	go func() {
+		defer close(errc)
 		defer close(c)
 
-		for year := time.Now().Year(); year >= 1994; year-- {
-			// Get archive page
-			archivePage, err := goquery.NewDocument(fmt.Sprintf(archiveURL, baseURL, year))
-			if err != nil {
-				log.Println(err)
-				break
+		if game.Name != lotto.GAME {
+			errc <- fmt.Errorf("%s only has %s results", ScraperName, lotto.GAME)
+			return
+		}
+
+		if err := s.loadRobots(ctx); err != nil {
+			errc <- err
+			return
+		}
+
+		failed := &ScrapeError{}
+		want := func(d time.Time) bool { return !d.Before(begin) && !d.After(end) }
+		for year := end.Year(); year >= begin.Year() && year >= 1994; year-- {
+			if err := s.scrapeYear(ctx, year, want, c, failed); err != nil {
+				errc <- err
+				return
 			}
+		}
 
-			// Find all results pages linked from archive page
-			archivePage.Find("#siteContainer .main .lotto tbody tr td a").Each(func(i int, s *goquery.Selection) {
-				resultURL, ok := s.Attr("href")
-				if !ok {
-					log.Println("No result URL for", s.Text())
-					return
-				}
+		if err := failed.result(); err != nil {
+			errc <- err
+		}
+	}()
 
-				res, err := parseResultPage(resultURL)
-				if err != nil {
-					log.Println(err)
-					return
-				}
+	return c, errc
+}
+
+// Dates fetches only the results drawn on the given dates, crawling just the
+// archive pages for the years those dates fall in
+func (s *Scraper) Dates(ctx context.Context, game lotto.Game, dates []time.Time) (<-chan lotto.Result, <-chan error) {
+	c, errc := make(chan lotto.Result), make(chan error, 1)
 
-				c <- res
-			})
+	var (
+		want  = make(map[string]bool)
+		years []int
+	)
+	for _, d := range dates {
+		want[d.Format("2006-01-02")] = true
+		if len(years) == 0 || years[len(years)-1] != d.Year() {
+			years = append(years, d.Year())
+		}
+	}
+
+	go func() {
+		defer close(errc)
+		defer close(c)
+
+		if game.Name != lotto.GAME {
+			errc <- fmt.Errorf("%s only has %s results", ScraperName, lotto.GAME)
+			return
+		}
+
+		if err := s.loadRobots(ctx); err != nil {
+			errc <- err
+			return
+		}
+
+		failed := &ScrapeError{}
+		for _, year := range years {
+			if err := s.scrapeYear(ctx, year, func(d time.Time) bool { return want[d.Format("2006-01-02")] }, c, failed); err != nil {
+				errc <- err
+				return
+			}
+		}
+
+		if err := failed.result(); err != nil {
+			errc <- err
 		}
 	}()
 
-	return c
+	return c, errc
 }
 
-func parseResultPage(url string) (lotto.Result, error) {
-	// Create new lotto.Result
-	res := lotto.NewResult()
+// scrapeYear parses every result page linked from the archive page for year that
+// is dated on a day accepted by want and sends the results to c. Pages that fail
+// are added to failed. Only cancelling ctx or a page that doesn't have the
+// structure the parser expects ends the scrape.
+func (s *Scraper) scrapeYear(ctx context.Context, year int, want func(time.Time) bool, c chan<- lotto.Result, failed *ScrapeError) error {
+	// Get archive page
+	pageURL := fmt.Sprintf(archiveURL, s.BaseURL, year)
+	archivePage, err := s.fetchPage(ctx, pageURL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		failed.add(err)
+		return nil
+	}
 
-	// Load results page
-	resultPage, err := goquery.NewDocument(fmt.Sprintf("%s%s", baseURL, url))
+	// Find all results pages linked from archive page
+	links, err := parseArchive(archivePage.doc, pageURL, year)
 	if err != nil {
-		log.Println(err)
-		return res, err
+		return err
+	}
+
+	var pages []archiveLink
+	for _, l := range links {
+		if want(l.date) {
+			pages = append(pages, l)
+		}
+	}
+
+	// Result pages are fetched by a pool of workers but each is sent to c in turn so
+	// that results still arrive newest first
+	type fetched struct {
+		res lotto.Result
+		err error
+	}
+	var (
+		jobs = make(chan int)
+		done = make([]chan fetched, len(pages))
+	)
+	for i := range done {
+		done[i] = make(chan fetched, 1)
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := s.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				res, err := s.parseResultPage(ctx, pages[i].url)
+				done[i] <- fetched{res, err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range pages {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for i := range pages {
+		var f fetched
+		select {
+		case f = <-done[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if se, ok := f.err.(*SelectorError); ok {
+			return se
+		}
+		if f.err != nil {
+			if ctx.Err() == nil {
+				failed.add(f.err)
+			}
+			continue
+		}
+
+		select {
+		case c <- f.res:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return ctx.Err()
+}
+
+// parseResultPage fetches and parses the result page at url, relative to the base
+// URL
+func (s *Scraper) parseResultPage(ctx context.Context, url string) (lotto.Result, error) {
+	pageURL := fmt.Sprintf("%s%s", s.BaseURL, url)
+	page, err := s.fetchPage(ctx, pageURL)
+	if err != nil {
+		return lotto.NewResult(), err
+	}
+
+	res, err := parseResult(page.doc, pageURL)
+	res.Provenance = lotto.Provenance{Source: pageURL, FetchedAt: page.fetchedAt, Hash: page.hash, ParserVersion: ScraperVersion}
+
+	return res, err
+}
+
+// archiveLink is a link to a result page found on an archive page
+type archiveLink struct {
+	url  string
+	date time.Time
+}
+
+// parseArchive returns the result pages linked from the archive page for year,
+// newest first
+func parseArchive(doc *goquery.Document, pageURL string, year int) ([]archiveLink, error) {
+	var (
+		links    []archiveLink
+		problems []string
+	)
+
+	anchors := doc.Find(selArchiveLinks)
+	anchors.Each(func(i int, sel *goquery.Selection) {
+		resultURL, ok := sel.Attr("href")
+		if !ok {
+			log.Println("No result URL for", sel.Text())
+			return
+		}
+
+		d, err := parseDateFromURL(resultURL)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("unparsable date in link %q", resultURL))
+			return
+		}
+		links = append(links, archiveLink{resultURL, d})
+	})
+
+	// A year that has only just begun may not have had a draw yet
+	from, to := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if now := time.Now(); to.After(now) {
+		to = now
+	}
+	if anchors.Length() == 0 && len(lotto.Lotto.DrawDates(from, to)) > 0 {
+		problems = append(problems, fmt.Sprintf("no result links found by %q", selArchiveLinks))
+	}
+
+	if len(problems) > 0 {
+		return nil, &SelectorError{URL: pageURL, Problems: problems}
+	}
+
+	sort.SliceStable(links, func(i, j int) bool { return links[i].date.After(links[j].date) })
+	return links, nil
+}
+
+// parseResult parses the result page fetched from pageURL
+func parseResult(doc *goquery.Document, pageURL string) (lotto.Result, error) {
+	var (
+		res      = lotto.NewResult()
+		problems []string
+		err      error
+	)
+
 	// Set lotto.Result date
-	if res.Date, err = parseDateFromURL(url); err != nil {
-		log.Println(err)
-		return res, err
+	if res.Date, err = parseDateFromURL(pageURL); err != nil {
+		problems = append(problems, "unparsable date in url")
 	}
 
 	// Set lotto.Result ball results
-	resultPage.Find(".result").Each(func(i int, s *goquery.Selection) {
-		result, err := strconv.Atoi(s.Text())
+	balls := doc.Find(selBalls)
+	if n := balls.Length(); n == 0 {
+		problems = append(problems, fmt.Sprintf("no balls found by %q", selBalls))
+	} else if n != len(res.Balls)+1 {
+		problems = append(problems, fmt.Sprintf("found %d balls, want %d", n, len(res.Balls)+1))
+	}
+	balls.Each(func(i int, s *goquery.Selection) {
+		result, err := strconv.Atoi(strings.TrimSpace(s.Text()))
 		if err != nil {
-			log.Println(err)
+			problems = append(problems, fmt.Sprintf("ball %d: %q is not a number", i+1, strings.TrimSpace(s.Text())))
 		}
 
 		if i < len(res.Balls) {
@@ -85,7 +459,11 @@ func parseResultPage(url string) (lotto.Result, error) {
 	})
 
 	// Set lotto.Result machine and set
-	resultPage.Find("#siteContainer .main .lotto tbody tr td").Each(func(i int, s *goquery.Selection) {
+	details := doc.Find(selDetails)
+	if details.Length() == 0 {
+		problems = append(problems, fmt.Sprintf("no draw details found by %q", selDetails))
+	}
+	details.Each(func(i int, s *goquery.Selection) {
 		if strings.Contains(s.Text(), "Set Used:") {
 			n, err := strconv.Atoi(parseUsed(s.Text()))
 			if err != nil {
@@ -98,19 +476,267 @@ func parseResultPage(url string) (lotto.Result, error) {
 		if strings.Contains(s.Text(), "Machine Used:") {
 			res.Machine = parseUsed(s.Text())
 		}
+
+		if strings.Contains(s.Text(), "Draw Number:") {
+			n, err := strconv.Atoi(strings.NewReplacer(",", "", "#", "").Replace(parseUsed(s.Text())))
+			if err != nil {
+				log.Println(err)
+			}
+
+			res.Draw = n
+		}
+
+		if strings.Contains(s.Text(), "Draw Time:") {
+			t, err := parseDrawTime(res.Date, parseUsed(s.Text()))
+			if err != nil {
+				log.Printf("%s: %s\n", pageURL, err)
+			}
+
+			res.DrawnAt = t
+		}
 	})
 
+	// Set lotto.Result special events. Only the event banners are read as the same
+	// phrases turn up elsewhere on the page, in news of upcoming draws and the like.
+	doc.Find(selEvents).Each(func(i int, s *goquery.Selection) {
+		text := strings.ToLower(s.Text())
+		for phrase, event := range eventPhrases {
+			if strings.Contains(text, phrase) && !res.HasEvent(event) {
+				res.Events = append(res.Events, event)
+			}
+		}
+	})
+	sort.Strings(res.Events)
+
+	if len(problems) > 0 {
+		return res, &SelectorError{URL: pageURL, Problems: problems}
+	}
 	return res, nil
 }
 
+// scrapedPage is a parsed page along with the SHA-256 hash of its raw contents and
+// when it was fetched
+type scrapedPage struct {
+	doc       *goquery.Document
+	body      []byte
+	hash      string
+	fetchedAt time.Time
+}
+
+// fetchPage retrieves the page at url, from the page cache when offline. Pages
+// fetched from the site are added to the cache if there is one.
+func (s *Scraper) fetchPage(ctx context.Context, url string) (scrapedPage, error) {
+	var (
+		page = scrapedPage{fetchedAt: time.Now().UTC()}
+		key  = strings.TrimPrefix(url, s.BaseURL)
+		body []byte
+		err  error
+	)
+
+	if s.Offline {
+		if s.CacheDir == "" {
+			return page, fmt.Errorf("no page cache to read %s from", key)
+		}
+		body, page.hash, page.fetchedAt, err = pageCache(s.CacheDir).get(key)
+		if err != nil {
+			return page, err
+		}
+	} else {
+		if err := s.allowed(ctx, url); err != nil {
+			return page, err
+		}
+
+		if body, err = s.get(ctx, url); err != nil {
+			return page, err
+		}
+		page.hash = hashBytes(body)
+
+		// The page has been fetched so a cache failure is only worth a warning
+		if s.CacheDir != "" {
+			if err := pageCache(s.CacheDir).put(key, body, page.fetchedAt); err != nil {
+				log.Printf("Caching %s: %s\n", key, err)
+			}
+		}
+	}
+
+	page.body = body
+	page.doc, err = goquery.NewDocumentFromReader(bytes.NewReader(body))
+	return page, err
+}
+
+// get fetches url, retrying server errors and network failures with exponential
+// backoff until Retries is used up
+func (s *Scraper) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.UserAgent)
+	req = req.WithContext(ctx)
+
+	for attempt := 0; ; attempt++ {
+		body, err := s.do(req)
+		if err == nil || attempt >= s.Retries || !retryable(err) || ctx.Err() != nil {
+			return body, err
+		}
+
+		d := backoff(s.Backoff, attempt)
+		if se, ok := err.(*statusError); ok && se.retryAfter > d {
+			d = se.retryAfter
+		}
+		log.Printf("%s, retrying in %s\n", err, d.Round(time.Millisecond))
+
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// do makes a single request once the rate limiter allows it
+func (s *Scraper) do(req *http.Request) ([]byte, error) {
+	s.limiterOnce.Do(func() { s.limiter = newRateLimiter(s.Rate, s.Burst) })
+	if err := s.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		se := &statusError{url: req.URL.String(), code: resp.StatusCode, status: resp.Status}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			se.retryAfter = time.Duration(secs) * time.Second
+		}
+		return nil, se
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// retryable returns true for errors that may not happen again: server errors, rate
+// limiting and network failures
+func retryable(err error) bool {
+	if se, ok := err.(*statusError); ok {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
+	}
+	return err != context.Canceled && err != context.DeadlineExceeded
+}
+
+// backoff returns the delay before retry attempt+1. It doubles with each attempt up
+// to maxBackoff and is jittered by up to half so that retries don't arrive together.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 0; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// loadRobots fetches the robots.txt of the site once. A missing robots.txt allows
+// everything but one that can't be fetched allows nothing, so the scrape fails.
+// Nothing is fetched when offline.
+func (s *Scraper) loadRobots(ctx context.Context) error {
+	if s.Offline {
+		return nil
+	}
+
+	s.robotsOnce.Do(func() {
+		u, err := url.Parse(s.BaseURL)
+		if err != nil {
+			s.robotsErr = err
+			return
+		}
+		u.Path, u.RawQuery = "/robots.txt", ""
+
+		body, err := s.get(ctx, u.String())
+		if se, ok := err.(*statusError); ok && se.code >= 400 && se.code < 500 && se.code != http.StatusTooManyRequests {
+			return
+		}
+		if err != nil {
+			s.robotsErr = fmt.Errorf("robots.txt unavailable: %s", err)
+			return
+		}
+
+		agent := strings.FieldsFunc(s.UserAgent, func(r rune) bool { return r == '/' || r == ' ' })
+		if len(agent) == 0 {
+			agent = []string{"*"}
+		}
+		if s.robots, s.robotsErr = parseRobots(bytes.NewReader(body), agent[0]); s.robotsErr != nil {
+			return
+		}
+
+		if s.robots.delay > 0 {
+			log.Printf("Obeying robots.txt crawl delay of %s\n", s.robots.delay)
+			s.limiter.slow(s.robots.delay)
+		}
+	})
+
+	return s.robotsErr
+}
+
+// allowed returns an error if robots.txt disallows fetching pageURL
+func (s *Scraper) allowed(ctx context.Context, pageURL string) error {
+	if err := s.loadRobots(ctx); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+
+	if !s.robots.allowed(u.RequestURI()) {
+		return fmt.Errorf("%s: disallowed by robots.txt", pageURL)
+	}
+	return nil
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
 func parseUsed(str string) string {
-	return strings.TrimSpace(strings.Split(str, ":")[1])
+	return strings.TrimSpace(strings.SplitN(str, ":", 2)[1])
+}
+
+// parseDrawTime returns the time of day in str on the day of date. Draw times are
+// given in UK time.
+func parseDrawTime(date time.Time, str string) (time.Time, error) {
+	str = strings.Replace(strings.ToLower(str), " ", "", -1)
+	for _, layout := range []string{"15:04", "3:04pm", "3.04pm", "3pm"} {
+		t, err := time.Parse(layout, str)
+		if err != nil {
+			continue
+		}
+
+		loc, err := time.LoadLocation("Europe/London")
+		if err != nil {
+			loc = time.UTC
+		}
+		return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, loc).UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unparsable draw time %q", str)
 }
 
 func parseDateFromURL(url string) (time.Time, error) {
-	s := strings.Split(url, "s-")
-	if len(s) == 2 {
-		return time.Parse("02-01-2006", s[1])
+	if i := strings.LastIndex(url, "s-"); i >= 0 {
+		return time.Parse("02-01-2006", url[i+2:])
 	}
 
 	return time.Now(), fmt.Errorf("bad url: %s", url)
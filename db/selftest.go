@@ -0,0 +1,266 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/nboughton/go-utils/json/file"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// goldenManifest is the file in a golden directory that lists its pages
+const goldenManifest = "golden.json"
+
+// GoldenPage is a stored copy of a page from the site along with what the scraper
+// made of it when it was recorded
+type GoldenPage struct {
+	Path   string        // Path the page was fetched from, relative to the base URL
+	File   string        // Name of the copy in the golden directory
+	Year   int           `json:",omitempty"` // Year of an archive page
+	Links  int           `json:",omitempty"` // Result links found on an archive page
+	Result *lotto.Result `json:",omitempty"` // Result parsed from a result page
+}
+
+// Check is the outcome of testing the scraper against one page
+type Check struct {
+	Page     string
+	Live     bool
+	Problems []string
+}
+
+// OK returns true if the page was parsed as expected
+func (c Check) OK() bool {
+	return len(c.Problems) == 0
+}
+
+// SelfTest parses the golden pages held in dir, if any have been recorded there,
+// and the latest live archive and result pages. Each page is checked for the
+// structure the scraper expects and golden pages for the values recorded with them.
+func (s *Scraper) SelfTest(ctx context.Context, dir string) ([]Check, error) {
+	var checks []Check
+
+	if dir != "" {
+		golden, err := readGolden(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		for _, g := range golden {
+			checks = append(checks, checkGolden(dir, g))
+		}
+	}
+
+	archive, result, err := s.latest(ctx)
+	if err != nil {
+		return checks, err
+	}
+	checks = append(checks, archive, result)
+
+	return checks, nil
+}
+
+// RecordGolden saves the latest live archive and result pages to dir as golden
+// pages, replacing any recorded from the same paths. Pages that fail their checks
+// aren't recorded.
+func (s *Scraper) RecordGolden(ctx context.Context, dir string) ([]GoldenPage, error) {
+	if err := s.loadRobots(ctx); err != nil {
+		return nil, err
+	}
+
+	golden, err := readGolden(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	year, links, archive, err := s.latestArchive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultURL := s.BaseURL + links[0].url
+	page, err := s.fetchPage(ctx, resultURL)
+	if err != nil {
+		return nil, err
+	}
+	res, err := parseResult(page.doc, resultURL)
+	if err == nil {
+		err = checkDrawNumber(res, resultURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := []struct {
+		g    GoldenPage
+		body []byte
+	}{
+		{GoldenPage{Path: fmt.Sprintf(archiveURL, "", year), Year: year, Links: len(links)}, archive.body},
+		{GoldenPage{Path: links[0].url, Result: &res}, page.body},
+	}
+
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		return nil, err
+	}
+
+	var added []GoldenPage
+	for _, r := range record {
+		r.g.File = strings.Trim(strings.Replace(r.g.Path, "/", "_", -1), "_") + ".html"
+		if err := writeAtomic(filepath.Join(dir, r.g.File), r.body); err != nil {
+			return added, err
+		}
+
+		kept := golden[:0]
+		for _, g := range golden {
+			if g.Path != r.g.Path {
+				kept = append(kept, g)
+			}
+		}
+		golden = append(kept, r.g)
+		added = append(added, r.g)
+	}
+
+	return added, file.Write(filepath.Join(dir, goldenManifest), golden)
+}
+
+// readGolden reads the manifest of the golden directory dir
+func readGolden(dir string) ([]GoldenPage, error) {
+	var golden []GoldenPage
+	if _, err := os.Stat(filepath.Join(dir, goldenManifest)); err != nil {
+		return nil, err
+	}
+
+	return golden, file.Scan(filepath.Join(dir, goldenManifest), &golden)
+}
+
+// checkGolden parses the golden page g and compares it with what was recorded
+func checkGolden(dir string, g GoldenPage) Check {
+	c := Check{Page: g.Path}
+
+	body, err := ioutil.ReadFile(filepath.Join(dir, g.File))
+	if err != nil {
+		c.Problems = append(c.Problems, err.Error())
+		return c
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		c.Problems = append(c.Problems, err.Error())
+		return c
+	}
+
+	if g.Result == nil {
+		links, err := parseArchive(doc, g.Path, g.Year)
+		if err != nil {
+			c.Problems = append(c.Problems, problems(err)...)
+		} else if len(links) != g.Links {
+			c.Problems = append(c.Problems, fmt.Sprintf("found %d result links, want %d", len(links), g.Links))
+		}
+		return c
+	}
+
+	res, err := parseResult(doc, g.Path)
+	if err == nil {
+		err = checkDrawNumber(res, g.Path)
+	}
+	if err != nil {
+		c.Problems = append(c.Problems, problems(err)...)
+		return c
+	}
+
+	want := *g.Result
+	if !res.Date.Equal(want.Date) {
+		c.Problems = append(c.Problems, fmt.Sprintf("date is %s, want %s", res.Date.Format("2006-01-02"), want.Date.Format("2006-01-02")))
+	}
+	if !reflect.DeepEqual(res.Balls, want.Balls) || res.Bonus != want.Bonus {
+		c.Problems = append(c.Problems, fmt.Sprintf("balls are %v %d, want %v %d", res.Balls, res.Bonus, want.Balls, want.Bonus))
+	}
+	if res.Draw != want.Draw || !res.DrawnAt.Equal(want.DrawnAt) {
+		c.Problems = append(c.Problems, fmt.Sprintf("draw is %d at %s, want %d at %s", res.Draw, formatDrawnAt(res.DrawnAt), want.Draw, formatDrawnAt(want.DrawnAt)))
+	}
+	if !reflect.DeepEqual(res.Events, want.Events) {
+		c.Problems = append(c.Problems, fmt.Sprintf("events are %v, want %v", res.Events, want.Events))
+	}
+	if res.Machine != want.Machine || res.Set != want.Set {
+		c.Problems = append(c.Problems, fmt.Sprintf("machine and set are %s:%d, want %s:%d", res.Machine, res.Set, want.Machine, want.Set))
+	}
+
+	return c
+}
+
+// checkDrawNumber returns a SelectorError if res has no draw number. Updates accept
+// pages without one, but every recent page has one so its absence from the pages
+// the self test reads means the draw details have moved.
+func checkDrawNumber(res lotto.Result, pageURL string) error {
+	if res.Draw == 0 {
+		return &SelectorError{URL: pageURL, Problems: []string{fmt.Sprintf("no draw number found by %q", selDetails)}}
+	}
+
+	return nil
+}
+
+// latest checks the latest live archive page and the newest result page it links to
+func (s *Scraper) latest(ctx context.Context) (Check, Check, error) {
+	if err := s.loadRobots(ctx); err != nil {
+		return Check{}, Check{}, err
+	}
+
+	year, links, _, err := s.latestArchive(ctx)
+
+	archive := Check{Page: fmt.Sprintf(archiveURL, s.BaseURL, year), Live: true}
+	result := Check{Live: true}
+	if err != nil {
+		archive.Problems = problems(err)
+		result.Problems = []string{"no archive page to find the latest result from"}
+		return archive, result, nil
+	}
+
+	result.Page = s.BaseURL + links[0].url
+	res, err := s.parseResultPage(ctx, links[0].url)
+	if err == nil {
+		err = checkDrawNumber(res, result.Page)
+	}
+	if err != nil {
+		result.Problems = problems(err)
+	}
+
+	return archive, result, nil
+}
+
+// latestArchive fetches and parses the archive page for the current year, or the
+// year before if there's been no draw yet this year
+func (s *Scraper) latestArchive(ctx context.Context) (int, []archiveLink, scrapedPage, error) {
+	year := time.Now().Year()
+	for {
+		pageURL := fmt.Sprintf(archiveURL, s.BaseURL, year)
+		page, err := s.fetchPage(ctx, pageURL)
+		if err != nil {
+			return year, nil, page, err
+		}
+
+		links, err := parseArchive(page.doc, pageURL, year)
+		if err != nil || len(links) > 0 || year < time.Now().Year() {
+			if err == nil && len(links) == 0 {
+				err = fmt.Errorf("%s: no results found", pageURL)
+			}
+			return year, links, page, err
+		}
+		year--
+	}
+}
+
+// problems returns the problems listed by a SelectorError or the text of any other
+// error
+func problems(err error) []string {
+	if se, ok := err.(*SelectorError); ok {
+		return se.Problems
+	}
+	return []string{err.Error()}
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goldenDir holds synthetic pages, written by hand in the structure the scraper
+// expects rather than recorded from the site, along with what the scraper should make
+// of them. They catch regressions in the parser but not changes to the site's markup,
+// which "scraper selftest" checks against the live site.
+var goldenDir = filepath.Join("testdata", "synthetic")
+
+// recordedDir holds any pages recorded from the site with "scraper selftest --record"
+var recordedDir = filepath.Join("testdata", "recorded")
+
+func TestGolden(t *testing.T) {
+	golden, err := readGolden(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(golden) == 0 {
+		t.Fatalf("no golden pages in %s", goldenDir)
+	}
+	checkGoldenDir(t, goldenDir, golden)
+
+	recorded, err := readGolden(recordedDir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	checkGoldenDir(t, recordedDir, recorded)
+}
+
+func checkGoldenDir(t *testing.T, dir string, golden []GoldenPage) {
+	for _, g := range golden {
+		if c := checkGolden(dir, g); !c.OK() {
+			t.Errorf("%s: %s", c.Page, strings.Join(c.Problems, "; "))
+		}
+	}
+}
+
+// TestGoldenMarkupChange checks that golden pages catch changes to the markup the
+// scraper depends on, as recorded pages would once the site changes
+func TestGoldenMarkupChange(t *testing.T) {
+	golden, err := readGolden(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []struct {
+		name    string
+		results bool // Only applies to result pages
+		r       *strings.Replacer
+	}{
+		{"classes", false, strings.NewReplacer(`class="result`, `class="ball`, `class="lotto"`, `class="draws"`)},
+		{"draw number label", true, strings.NewReplacer("Draw Number:", "Draw No.")},
+	}
+
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, ch := range changes {
+		for _, g := range golden {
+			if ch.results && g.Result == nil {
+				continue
+			}
+
+			body, err := ioutil.ReadFile(filepath.Join(goldenDir, g.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body = []byte(ch.r.Replace(string(body)))
+			if err := ioutil.WriteFile(filepath.Join(dir, g.File), body, 0660); err != nil {
+				t.Fatal(err)
+			}
+
+			if c := checkGolden(dir, g); c.OK() {
+				t.Errorf("%s: changed %s passed its checks", g.Path, ch.name)
+			}
+		}
+	}
+}
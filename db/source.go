@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Source is implemented by anything that can provide the results of a game
+type Source interface {
+	Name() string
+	// Results sends the results of game drawn between begin and end over the first
+	// channel returned, newest first. The second channel receives at most one error
+	// and is closed once the results channel has been closed.
+	Results(ctx context.Context, game lotto.Game, begin, end time.Time) (<-chan lotto.Result, <-chan error)
+}
+
+// DateSource is implemented by sources that can fetch the results of individual
+// draws more cheaply than those of the whole range they span
+type DateSource interface {
+	Source
+	Dates(ctx context.Context, game lotto.Game, dates []time.Time) (<-chan lotto.Result, <-chan error)
+}
+
+// SourceByName returns the Source called name. The scraper is called ScraperName
+// and files are named by their format and path, for example csv:results.csv.
+func SourceByName(name string) (Source, error) {
+	if name == ScraperName {
+		return NewScraper(nil, "", ""), nil
+	}
+
+	if i := strings.Index(name, ":"); i > 0 {
+		switch format := name[:i]; format {
+		case FormatJSON, FormatCSV, FormatOperator:
+			return FileSource{Format: format, Path: name[i+1:]}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown source %q", name)
+}
+
+// FileSource is a Source that reads results from a file in one of the formats
+// understood by ReadFile
+type FileSource struct {
+	Format string
+	Path   string
+}
+
+// Name satisfies the Source interface
+func (f FileSource) Name() string {
+	return f.Format + ":" + f.Path
+}
+
+// Results satisfies the Source interface
+func (f FileSource) Results(ctx context.Context, game lotto.Game, begin, end time.Time) (<-chan lotto.Result, <-chan error) {
+	c, errc := make(chan lotto.Result), make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(c)
+
+		set, err := ReadFile(f.Path, f.Format)
+		if err != nil {
+			errc <- err
+			return
+		}
+		sort.SliceStable(set, func(i, j int) bool { return set[i].Date.After(set[j].Date) })
+
+		for _, res := range set {
+			if res.Game == "" {
+				res.Game = lotto.GAME
+			}
+			res.Date = res.Date.UTC()
+
+			if res.Game != game.Name || res.Date.Before(begin) || res.Date.After(end) {
+				continue
+			}
+
+			select {
+			case c <- res:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return c, errc
+}
+
+// Fallback is a Source that tries each of its sources in turn. When a source fails
+// part way through the next picks up from the last result delivered.
+type Fallback []Source
+
+// Name satisfies the Source interface
+func (f Fallback) Name() string {
+	names := make([]string, len(f))
+	for i, src := range f {
+		names[i] = src.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Results satisfies the Source interface
+func (f Fallback) Results(ctx context.Context, game lotto.Game, begin, end time.Time) (<-chan lotto.Result, <-chan error) {
+	c, errc := make(chan lotto.Result), make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(c)
+
+		var err error
+		for _, src := range f {
+			results, srcErr := src.Results(ctx, game, begin, end)
+			for res := range results {
+				select {
+				case c <- res:
+					// Results arrive newest first so anything older is still to come
+					end = res.Date.Add(-time.Second)
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+
+			if err = <-srcErr; err == nil {
+				return
+			}
+			log.Printf("%s: %s\n", src.Name(), err)
+		}
+
+		if err != nil {
+			errc <- err
+		}
+	}()
+
+	return c, errc
+}
+
+// Dates satisfies the DateSource interface. Dates that one source can't provide are
+// asked of the next.
+func (f Fallback) Dates(ctx context.Context, game lotto.Game, dates []time.Time) (<-chan lotto.Result, <-chan error) {
+	c, errc := make(chan lotto.Result), make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(c)
+
+		remaining := dates
+		for _, src := range f {
+			got := make(map[string]bool)
+			results, srcErr := fetchDates(ctx, src, game, remaining)
+			for res := range results {
+				got[res.Date.Format("2006-01-02")] = true
+				select {
+				case c <- res:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+			if err := <-srcErr; err != nil {
+				log.Printf("%s: %s\n", src.Name(), err)
+			}
+
+			var still []time.Time
+			for _, d := range remaining {
+				if !got[d.Format("2006-01-02")] {
+					still = append(still, d)
+				}
+			}
+			if remaining = still; len(remaining) == 0 {
+				return
+			}
+		}
+	}()
+
+	return c, errc
+}
+
+// fetchDates fetches the results of game drawn on dates from src. Sources that
+// can't fetch individual draws are asked for the whole range the dates span.
+func fetchDates(ctx context.Context, src Source, game lotto.Game, dates []time.Time) (<-chan lotto.Result, <-chan error) {
+	if ds, ok := src.(DateSource); ok {
+		return ds.Dates(ctx, game, dates)
+	}
+
+	c, errc := make(chan lotto.Result), make(chan error, 1)
+	if len(dates) == 0 {
+		close(c)
+		close(errc)
+		return c, errc
+	}
+
+	want := make(map[string]bool)
+	begin, end := dates[0], dates[0]
+	for _, d := range dates {
+		want[d.Format("2006-01-02")] = true
+		if d.Before(begin) {
+			begin = d
+		}
+		if d.After(end) {
+			end = d
+		}
+	}
+
+	go func() {
+		defer close(errc)
+		defer close(c)
+
+		results, srcErr := src.Results(ctx, game, begin, end.Add(24*time.Hour-time.Second))
+		for res := range results {
+			if !want[res.Date.Format("2006-01-02")] {
+				continue
+			}
+
+			select {
+			case c <- res:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+
+		if err := <-srcErr; err != nil {
+			errc <- err
+		}
+	}()
+
+	return c, errc
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"fmt"
+
+	query "github.com/nboughton/go-sqgenlite"
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Frequencies returns the frequency sets for balls and bonus balls in the draws
+// matched by f, counted by the database rather than by loading every draw. Sets
+// are cached until a draw matched by f changes.
+func (db *AppDB) Frequencies(f Filter) (balls lotto.FrequencySet, bonus lotto.FrequencySet, err error) {
+	var (
+		k  = db.newCacheKey(statFrequencies, f, 0)
+		fs frequencies
+	)
+	if db.cached(k, &fs) {
+		return fs.Balls, fs.Bonus, nil
+	}
+
+	if balls, bonus, err = db.frequencies(f); err == nil {
+		db.cache(k, frequencies{Balls: balls, Bonus: bonus})
+	}
+
+	return balls, bonus, err
+}
+
+func (db *AppDB) frequencies(f Filter) (balls lotto.FrequencySet, bonus lotto.FrequencySet, err error) {
+	q := db.matching(query.NewQuery().
+		Select("draw_balls b JOIN results r ON r.id = b.draw_id", "b.ball", "b.is_bonus", "COUNT(b.ball)"), f, "r").
+		Group("b.ball", "b.is_bonus")
+
+	rows, err := db.Query(q.SQL.String(), q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	balls, bonus = lotto.NewFrequencySet(lotto.MAXBALLVAL), lotto.NewFrequencySet(lotto.MAXBALLVAL)
+	for rows.Next() {
+		var (
+			ball, freq int
+			isBonus    bool
+		)
+		if err := rows.Scan(&ball, &isBonus, &freq); err != nil {
+			return nil, nil, err
+		}
+
+		if ball < 1 || ball > lotto.MAXBALLVAL {
+			continue
+		}
+
+		if isBonus {
+			bonus[ball-1].Frequency = freq
+		} else {
+			balls[ball-1].Frequency = freq
+		}
+	}
+
+	return balls, bonus, rows.Err()
+}
+
+// BallGap records how many draws have passed since a ball was last drawn and the
+// longest run of draws it has gone without being drawn
+type BallGap struct {
+	Ball    int
+	Current int
+	Longest int
+}
+
+// Gaps returns the draw gap stats for each main ball in the draws matched by f.
+// Stats are cached until a draw matched by f changes.
+func (db *AppDB) Gaps(f Filter) ([]BallGap, error) {
+	var (
+		k    = db.newCacheKey(statGaps, f, 0)
+		gaps []BallGap
+	)
+	if db.cached(k, &gaps) {
+		return gaps, nil
+	}
+
+	gaps, err := db.gaps(f)
+	if err == nil {
+		db.cache(k, gaps)
+	}
+
+	return gaps, err
+}
+
+func (db *AppDB) gaps(f Filter) ([]BallGap, error) {
+	d := db.matching(query.NewQuery().Select("results r", "r.id", "ROW_NUMBER() OVER (ORDER BY r.date) AS n"), f, "r")
+
+	q := query.NewQuery().
+		Append(fmt.Sprintf(`WITH d AS (%s),
+			seen AS (SELECT b.ball, d.n, d.n - COALESCE(LAG(d.n) OVER (PARTITION BY b.ball ORDER BY d.n), 0) - 1 AS gap
+				FROM draw_balls b JOIN d ON d.id = b.draw_id WHERE b.is_bonus = 0)`, d.SQL.String()), d.Args...).
+		Append("SELECT ball, (SELECT MAX(n) FROM d) - MAX(n), MAX(gap) FROM seen").
+		Group("ball").
+		Order("ball")
+
+	rows, err := db.Query(q.SQL.String(), q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []BallGap
+	for rows.Next() {
+		var g BallGap
+		if err := rows.Scan(&g.Ball, &g.Current, &g.Longest); err != nil {
+			return nil, err
+		}
+
+		// The run since a ball was last drawn may be longer than any run between draws
+		if g.Current > g.Longest {
+			g.Longest = g.Current
+		}
+		res = append(res, g)
+	}
+
+	return res, rows.Err()
+}
+
+// BallPair records how many times two main balls have been drawn together
+type BallPair struct {
+	A    int
+	B    int
+	Freq int
+}
+
+// CoOccurrence returns the limit most frequently drawn pairs of main balls in the
+// draws matched by f. Pairs are cached until a draw matched by f changes.
+func (db *AppDB) CoOccurrence(f Filter, limit int) ([]BallPair, error) {
+	var (
+		k     = db.newCacheKey(statPairs, f, limit)
+		pairs []BallPair
+	)
+	if db.cached(k, &pairs) {
+		return pairs, nil
+	}
+
+	pairs, err := db.coOccurrence(f, limit)
+	if err == nil {
+		db.cache(k, pairs)
+	}
+
+	return pairs, err
+}
+
+func (db *AppDB) coOccurrence(f Filter, limit int) ([]BallPair, error) {
+	q := db.matching(query.NewQuery().
+		Select(`draw_balls a JOIN draw_balls b ON b.draw_id = a.draw_id AND a.ball < b.ball AND a.is_bonus = 0 AND b.is_bonus = 0
+			JOIN results r ON r.id = a.draw_id`, "a.ball", "b.ball", "COUNT(a.ball) AS freq"), f, "r").
+		Group("a.ball", "b.ball").
+		Order("freq DESC", "a.ball", "b.ball").
+		Append("LIMIT ?", limit)
+
+	rows, err := db.Query(q.SQL.String(), q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []BallPair
+	for rows.Next() {
+		var p BallPair
+		if err := rows.Scan(&p.A, &p.B, &p.Freq); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Store is implemented by anything that can hold and query lotto results
+type Store interface {
+	Updater
+	Result(t time.Time) (lotto.Result, error)
+	Results(ctx context.Context, f Filter) (<-chan lotto.Result, <-chan error)
+	Machines(f Filter) ([]string, error)
+	Sets(f Filter) ([]int, error)
+	LastDraw() (lotto.Result, error)
+	DataRange() (time.Time, time.Time, error)
+	MachineSetFreq(f Filter) ([]MacSetFreq, error)
+	Frequencies(f Filter) (balls lotto.FrequencySet, bonus lotto.FrequencySet, err error)
+	Gaps(f Filter) ([]BallGap, error)
+	CoOccurrence(f Filter, limit int) ([]BallPair, error)
+}
+
+// Open returns the Store for path. postgres:// DSNs connect to a PostgreSQL database,
+// paths ending in .json are loaded into an in-memory store from a JSON export and
+// anything else is treated as a SQLite database.
+func Open(path string, opts Options) Store {
+	if IsPostgres(path) {
+		return ConnectPostgres(path, opts)
+	}
+
+	if strings.HasSuffix(path, ".json") {
+		m := NewMemDB()
+		if err := m.LoadJSON(path); err != nil {
+			log.Fatal(err)
+		}
+		return m
+	}
+
+	return Connect(path, opts)
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Updater is the part of a Store that updates from a Source need
+type Updater interface {
+	Exists(game string, t time.Time) bool
+	Upsert(res lotto.Result) error
+	Missing(game lotto.Game, begin, end time.Time) ([]time.Time, error)
+}
+
+// snapshotter is implemented by stores that can snapshot themselves before a change
+type snapshotter interface {
+	autoSnapshot(reason string) error
+}
+
+// Update fetches the results of game from src, newest first, and adds them to s
+// until an existing record is found.
+func Update(s Updater, src Source, game lotto.Game) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results, errc := src.Results(ctx, game, game.FirstDraw(), time.Now())
+	for res := range results {
+		if s.Exists(res.Game, res.Date) {
+			return fmt.Errorf("update done")
+		}
+
+		err := s.Upsert(res)
+		if err == ErrManual {
+			log.Printf("%s: %s\n", res.Date.Format("2006-01-02"), err)
+			continue
+		}
+		if isInvalid(err) {
+			log.Println(err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Inserted: %+v \n", res)
+	}
+
+	return <-errc
+}
+
+// Refresh fetches every draw of game between begin and end from src and stores it in
+// s, replacing any stored values other than manual corrections. It returns the number
+// of draws stored and the number skipped because they've been corrected by hand.
+func Refresh(s Updater, src Source, game lotto.Game, begin, end time.Time) (int, int, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n, skipped := 0, 0
+	results, errc := src.Results(ctx, game, begin, end)
+	for res := range results {
+		err := s.Upsert(res)
+		if err == ErrManual {
+			log.Printf("%s: %s\n", res.Date.Format("2006-01-02"), err)
+			skipped++
+			continue
+		}
+		if isInvalid(err) {
+			log.Println(err)
+			continue
+		}
+		if err != nil {
+			return n, skipped, err
+		}
+		log.Printf("Stored: %+v \n", res)
+		n++
+	}
+
+	return n, skipped, <-errc
+}
+
+// Resync finds every draw of game scheduled between begin and end that is missing
+// from s and fetches just those draws from src. Stores that take automatic snapshots
+// take one before a full resync. It returns the number of draws inserted.
+func Resync(s Updater, src Source, game lotto.Game, begin, end time.Time) (int, error) {
+	missing, err := s.Missing(game, begin, end)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	log.Printf("%d draws missing between %s and %s\n", len(missing), begin.Format("2006-01-02"), end.Format("2006-01-02"))
+
+	if sn, ok := s.(snapshotter); ok && !begin.After(game.FirstDraw()) {
+		if err := sn.autoSnapshot("full resync"); err != nil {
+			return 0, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := 0
+	results, errc := fetchDates(ctx, src, game, missing)
+	for res := range results {
+		err := s.Upsert(res)
+		if err == ErrManual {
+			log.Printf("%s: %s\n", res.Date.Format("2006-01-02"), err)
+			continue
+		}
+		if isInvalid(err) {
+			log.Println(err)
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		log.Printf("Inserted: %+v \n", res)
+		n++
+	}
+
+	return n, <-errc
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nboughton/stalotto/lotto"
+)
+
+// Issue describes a problem found with a stored result
+type Issue struct {
+	Result  lotto.Result
+	Problem string
+}
+
+// Verify checks every result in s against the rules and schedule of its game and
+// returns any issues found
+func Verify(ctx context.Context, s Store) ([]Issue, error) {
+	var issues []Issue
+	add := func(res lotto.Result, format string, args ...interface{}) {
+		issues = append(issues, Issue{Result: res, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	seen := make(map[string]lotto.Result)
+	results, errc := s.Results(ctx, Filter{SortBy: SortDate})
+	for res := range results {
+		// Each game is drawn at most once a day
+		key := res.Game + " " + res.Date.Format("2006-01-02")
+		if first, ok := seen[key]; ok {
+			add(res, "%s is stored more than once for %s, as draw %d and draw %d", res, res.Game, first.Draw, res.Draw)
+		} else {
+			seen[key] = res
+		}
+
+		game, err := lotto.GameByName(res.Game)
+		if err != nil {
+			add(res, "%s: %s", res, err)
+			continue
+		}
+
+		if err := res.Validate(game); err != nil {
+			add(res, "%s", err)
+		}
+
+		if !game.IsDrawDay(res.Date) {
+			add(res, "%s is off schedule, %s isn't normally drawn on a %s", res, game.Name, res.Date.Weekday())
+		}
+
+		if res.Machine == "" {
+			add(res, "%s is missing its machine", res)
+		}
+
+		if res.Set == 0 {
+			add(res, "%s is missing its ball set", res)
+		}
+	}
+
+	return issues, <-errc
+}
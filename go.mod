@@ -4,6 +4,7 @@ go 1.13
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.0
+	github.com/lib/pq v1.9.0
 	github.com/mattn/go-sqlite3 v1.11.0
 	github.com/nboughton/go-sqgenlite v0.1.0
 	github.com/nboughton/go-utils v0.0.0-20190619145535-d1fe1c39566f
@@ -0,0 +1,140 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package lotto
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVHeader is the header row written by WriteCSV and expected by ReadCSV. The draw
+// time is written in RFC 3339 and events are separated by commas.
+var CSVHeader = []string{"game", "draw", "date", "machine", "set", "ball1", "ball2", "ball3", "ball4", "ball5", "ball6", "bonus", "drawn_at", "events"}
+
+// csvOldColumns is the number of columns in files written before the draw time and
+// events were exported, which ReadCSV still accepts
+const csvOldColumns = 12
+
+// WriteCSV writes the set to w as CSV with a header row
+func (s ResultSet) WriteCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	if err := c.Write(CSVHeader); err != nil {
+		return err
+	}
+
+	for _, r := range s {
+		row := []string{r.Game, strconv.Itoa(r.Draw), r.Date.Format("2006-01-02"), r.Machine, strconv.Itoa(r.Set)}
+		for _, b := range r.Balls {
+			row = append(row, strconv.Itoa(b))
+		}
+		row = append(row, strconv.Itoa(r.Bonus))
+
+		drawnAt := ""
+		if !r.DrawnAt.IsZero() {
+			drawnAt = r.DrawnAt.UTC().Format(time.RFC3339)
+		}
+		row = append(row, drawnAt, strings.Join(r.Events, ","))
+
+		if err := c.Write(row); err != nil {
+			return err
+		}
+	}
+
+	c.Flush()
+	return c.Error()
+}
+
+// ReadCSV reads a set written by WriteCSV
+func ReadCSV(r io.Reader) (ResultSet, error) {
+	c := csv.NewReader(r)
+
+	// Every row must have as many columns as the header
+	c.FieldsPerRecord = 0
+
+	rows, err := c.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	if n := len(rows[0]); n != len(CSVHeader) && n != csvOldColumns {
+		return nil, fmt.Errorf("line 1: %d columns, want %d", n, len(CSVHeader))
+	}
+
+	var set ResultSet
+	for i, row := range rows[1:] {
+		res, err := parseCSVRow(row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+2, err)
+		}
+		set = append(set, res)
+	}
+
+	return set, nil
+}
+
+func parseCSVRow(row []string) (Result, error) {
+	var (
+		res = NewResult()
+		err error
+	)
+
+	res.Game, res.Machine = row[0], row[3]
+
+	if res.Date, err = time.Parse("2006-01-02", row[2]); err != nil {
+		return res, err
+	}
+
+	ints := []*int{&res.Draw, &res.Set}
+	for i := range res.Balls {
+		ints = append(ints, &res.Balls[i])
+	}
+	ints = append(ints, &res.Bonus)
+
+	for i, col := range []int{1, 4, 5, 6, 7, 8, 9, 10, 11} {
+		if *ints[i], err = strconv.Atoi(row[col]); err != nil {
+			return res, fmt.Errorf("%s: %s", CSVHeader[col], err)
+		}
+	}
+
+	if len(row) == csvOldColumns {
+		return res, nil
+	}
+
+	if row[12] != "" {
+		if res.DrawnAt, err = time.Parse(time.RFC3339, row[12]); err != nil {
+			return res, fmt.Errorf("%s: %s", CSVHeader[12], err)
+		}
+		res.DrawnAt = res.DrawnAt.UTC()
+	}
+	if row[13] != "" {
+		res.Events = strings.Split(row[13], ",")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,41 @@
+package lotto
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	set := ResultSet{
+		{Game: GAME, Draw: 2517, Date: time.Date(2019, 12, 7, 0, 0, 0, 0, time.UTC), DrawnAt: time.Date(2019, 12, 7, 20, 2, 0, 0, time.UTC), Events: []string{EventMustBeWon, EventRaffle}, Machine: "Lancelot", Set: 3, Balls: []int{9, 16, 23, 38, 44, 51}, Bonus: 2},
+		{Game: GAME, Date: time.Date(2019, 12, 4, 0, 0, 0, 0, time.UTC), Machine: "Arthur", Set: 8, Balls: []int{1, 2, 3, 4, 5, 6}, Bonus: 7},
+	}
+
+	var buf bytes.Buffer
+	if err := set.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, set) {
+		t.Errorf("read back %+v, want %+v", got, set)
+	}
+}
+
+func TestReadCSVOldColumns(t *testing.T) {
+	in := "game,draw,date,machine,set,ball1,ball2,ball3,ball4,ball5,ball6,bonus\nlotto,2501,2020-01-01,Arthur,8,9,37,55,52,49,5,17\n"
+
+	set, err := ReadCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 1 || set[0].Draw != 2501 || set[0].Bonus != 17 || !set[0].DrawnAt.IsZero() || set[0].Events != nil {
+		t.Errorf("read %+v", set)
+	}
+}
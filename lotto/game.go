@@ -0,0 +1,132 @@
+// Copyright © 2018 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package lotto
+
+import (
+	"fmt"
+	"time"
+)
+
+// Game describes a lottery game, the balls it draws and the schedule it is drawn on
+type Game struct {
+	Name     string
+	Balls    int
+	Eras     []BallRange
+	Schedule []DrawDays
+}
+
+// BallRange records the highest numbered ball in a game from a given date
+type BallRange struct {
+	From time.Time
+	Max  int
+}
+
+// DrawDays records the days of the week that a game is drawn on from a given date
+type DrawDays struct {
+	From time.Time
+	Days []time.Weekday
+}
+
+// Lotto is the main UK National Lottery game. It was drawn on Saturdays from
+// November 1994 with Wednesday draws added from February 1997. Balls were numbered
+// 1 to 49 until October 2015 when the range was increased to 59.
+var Lotto = Game{
+	Name:  GAME,
+	Balls: BALLS,
+	Eras: []BallRange{
+		{From: time.Date(1994, time.November, 19, 0, 0, 0, 0, time.UTC), Max: 49},
+		{From: time.Date(2015, time.October, 10, 0, 0, 0, 0, time.UTC), Max: MAXBALLVAL},
+	},
+	Schedule: []DrawDays{
+		{From: time.Date(1994, time.November, 19, 0, 0, 0, 0, time.UTC), Days: []time.Weekday{time.Saturday}},
+		{From: time.Date(1997, time.February, 5, 0, 0, 0, 0, time.UTC), Days: []time.Weekday{time.Wednesday, time.Saturday}},
+	},
+}
+
+// Games holds every known game by name
+var Games = map[string]Game{
+	Lotto.Name: Lotto,
+}
+
+// GameByName returns the game called name
+func GameByName(name string) (Game, error) {
+	g, ok := Games[name]
+	if !ok {
+		return g, fmt.Errorf("unknown game %q", name)
+	}
+
+	return g, nil
+}
+
+// MaxBall returns the highest numbered ball that could be drawn on the day of t
+func (g Game) MaxBall(t time.Time) int {
+	max := 0
+	for _, e := range g.Eras {
+		if !t.Before(e.From) {
+			max = e.Max
+		}
+	}
+
+	return max
+}
+
+// FirstDraw returns the date of the first draw of the game
+func (g Game) FirstDraw() time.Time {
+	if len(g.Schedule) == 0 {
+		return time.Time{}
+	}
+
+	return g.Schedule[0].From
+}
+
+// IsDrawDay returns true if the game is scheduled to be drawn on the day of t
+func (g Game) IsDrawDay(t time.Time) bool {
+	var days []time.Weekday
+	for _, s := range g.Schedule {
+		if !t.Before(s.From) {
+			days = s.Days
+		}
+	}
+
+	for _, d := range days {
+		if t.Weekday() == d {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DrawDates returns the dates of every scheduled draw between begin and end inclusive
+func (g Game) DrawDates(begin, end time.Time) []time.Time {
+	var (
+		out []time.Time
+		day = time.Date(begin.Year(), begin.Month(), begin.Day(), 0, 0, 0, 0, time.UTC)
+	)
+
+	for ; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if g.IsDrawDay(day) {
+			out = append(out, day)
+		}
+	}
+
+	return out
+}
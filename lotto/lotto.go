@@ -31,24 +31,95 @@ import (
 const (
 	MAXBALLVAL = 59
 	BALLS      = 6
+	GAME       = "lotto"
 )
 
 // Result represents a single Lotto draw result
 type Result struct {
-	Date    time.Time
-	Machine string
-	Set     int
-	Balls   []int
-	Bonus   int
+	Game       string
+	Draw       int       // Official draw number, 0 if not known
+	Date       time.Time // Day of the draw
+	DrawnAt    time.Time // Time the draw was made, zero if not known
+	Events     []string  `json:",omitempty"` // Special events such as EventMustBeWon
+	Machine    string
+	Set        int
+	Balls      []int
+	Bonus      int
+	Provenance Provenance
+}
+
+// Special events that a draw can be part of
+const (
+	EventMustBeWon = "must-be-won" // The jackpot had rolled over to its cap and had to be won
+	EventRaffle    = "raffle"      // Extra raffle prizes were drawn
+)
+
+// HasEvent returns true if the draw was part of the special event e
+func (r Result) HasEvent(e string) bool {
+	for _, v := range r.Events {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceManual is the Provenance source of results entered or corrected by hand
+const SourceManual = "manual"
+
+// Provenance records where a Result came from so that bad data can be traced
+type Provenance struct {
+	Source        string    // Page URL, file URL or SourceManual
+	FetchedAt     time.Time // When the result was fetched or imported
+	Hash          string    // SHA-256 of the payload the result was parsed from
+	ParserVersion string
 }
 
 // NewResult sets up a new Result struct for use
 func NewResult() Result {
 	var res Result
+	res.Game = GAME
 	res.Balls = make([]int, BALLS)
 	return res
 }
 
+// Validate returns an error if r could not have been drawn in game. Results that
+// fall on days the game isn't normally drawn are not rejected as the schedule has
+// occasionally been changed for special draws.
+func (r Result) Validate(game Game) error {
+	if r.Game != game.Name {
+		return fmt.Errorf("%s is not a %s result", r, game.Name)
+	}
+
+	if r.Date.Before(game.FirstDraw()) {
+		return fmt.Errorf("%s is dated before the first %s draw", r, game.Name)
+	}
+
+	if r.Date.After(time.Now()) {
+		return fmt.Errorf("%s is dated in the future", r)
+	}
+
+	if len(r.Balls) != game.Balls {
+		return fmt.Errorf("%s has %d balls, %s draws %d", r, len(r.Balls), game.Name, game.Balls)
+	}
+
+	var (
+		max  = game.MaxBall(r.Date)
+		seen = make(map[int]bool)
+	)
+	for _, b := range append(append([]int{}, r.Balls...), r.Bonus) {
+		if b < 1 || b > max {
+			return fmt.Errorf("%s has ball %d outside of 1-%d", r, b, max)
+		}
+		if seen[b] {
+			return fmt.Errorf("%s draws ball %d more than once", r, b)
+		}
+		seen[b] = true
+	}
+
+	return nil
+}
+
 // String satisfies the Stringer interface for Result
 func (r Result) String() string {
 	return fmt.Sprintf("%s %s:%d %d %d", r.Date.Format("2006-01-02"), r.Machine, r.Set, r.Balls, r.Bonus)
@@ -57,6 +128,15 @@ func (r Result) String() string {
 // ResultSet represents a collection of Results
 type ResultSet []Result
 
+// NewFrequencySet returns a FrequencySet with an entry for every ball from 1 to max
+func NewFrequencySet(max int) FrequencySet {
+	f := make(FrequencySet, max)
+	for i := range f {
+		f[i].Ball = i + 1
+	}
+	return f
+}
+
 // ByDrawFrequency returns the frequency sets for balls and bonus balls
 func (s ResultSet) ByDrawFrequency() (balls FrequencySet, bonus FrequencySet) {
 	balls = make(FrequencySet, MAXBALLVAL+1)
@@ -75,14 +155,14 @@ func (s ResultSet) ByDrawFrequency() (balls FrequencySet, bonus FrequencySet) {
 }
 
 // Drawn represents a record of a ball number and how often it has been drawn
-type drawn struct {
+type Drawn struct {
 	Ball      int
 	Frequency int
 }
 
 // FrequencySet represents a collection of balls that can be ordered
 // by draw frequency. FrequencySet also satisfies the Sort interface
-type FrequencySet []drawn
+type FrequencySet []Drawn
 
 // Len, Swap and Less satisfy the Sort interface for FrequencySet
 func (f FrequencySet) Len() int           { return len(f) }